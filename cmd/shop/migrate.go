@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+const migrateUsage = "usage: shop migrate up|down|status"
+
+// runMigrate manages MongoDB schema migrations. PostgreSQL and SQLite
+// apply their migrations on startup.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	if cfg.DBDriver != "mongo" {
+		return fmt.Errorf("migrations are managed for mongo driver only, got %q", cfg.DBDriver)
+	}
+
+	cfg.Mongo.MigrateOnStart = false
+
+	db, err := mongo.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return db.MigrateUp(ctx)
+	case "down":
+		return db.MigrateDown(ctx)
+	case "status":
+		return printMigrationStatus(ctx, db)
+	}
+
+	return errors.New(migrateUsage)
+}
+
+func printMigrationStatus(ctx context.Context, db *mongo.DB) error {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+
+	return w.Flush()
+}
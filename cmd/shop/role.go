@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+const roleUsage = "usage: shop role grant|revoke <user id> seller|staff|admin"
+
+// runRole grants or revokes a role right in the storage. It's how the
+// first admin is made, the others can be managed over the API.
+func runRole(cfg *config.Config, args []string) error {
+	if len(args) != 3 {
+		return errors.New(roleUsage)
+	}
+
+	role, err := domain.ParseRole(args[2])
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, roleUsage)
+	}
+
+	db, err := initDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "grant":
+		return db.AddUserRole(ctx, args[1], role)
+	case "revoke":
+		return db.RemoveUserRole(ctx, args[1], role)
+	}
+
+	return errors.New(roleUsage)
+}
@@ -1,18 +1,30 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/uber/jaeger-client-go"
 	jaegercfg "github.com/uber/jaeger-client-go/config"
-	"github.com/uber/jaeger-lib/metrics"
+	jprom "github.com/uber/jaeger-lib/metrics/prometheus"
 
+	dbi "github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/instrumented"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/memory"
 	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/postgres"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/sqlite"
 	"github.com/Pavel7004/WebShop/pkg/adapters/http"
+	"github.com/Pavel7004/WebShop/pkg/components/policy"
 	"github.com/Pavel7004/WebShop/pkg/components/shop"
 	"github.com/Pavel7004/WebShop/pkg/infra/config"
 )
@@ -29,15 +41,110 @@ func main() {
 		return
 	}
 
-	db := mongo.New(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			log.Error().Err(err).Msg("Migration failed")
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		if err := runRole(cfg, os.Args[2:]); err != nil {
+			log.Error().Err(err).Msg("Failed to change role")
+		}
+		return
+	}
+
+	if cfg.Auth.Secret == "" {
+		log.Warn().Msg("AUTH_SECRET isn't set, tokens are signed with a random secret and won't survive restarts")
+		cfg.Auth.Secret = randomSecret()
+	}
+
+	db, err := initDB(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize storage")
+		return
+	}
+
+	core := shop.New(instrumented.New(db, cfg.DBDriver), cfg)
+	server := http.New(policy.New(core), cfg)
 
-	shop := shop.New(db)
-	server := http.New(shop, cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	log.Info().Msg("Starting server")
-	if err := server.Run(); err != nil {
-		log.Error().Err(err).Msg("Server error")
+	if cfg.OrderExpiryPeriod > 0 {
+		go core.ExpireOrdersEvery(ctx, cfg.OrderExpiryPeriod)
 	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Info().Str("addr", cfg.HTTP.Addr).Msg("Starting server")
+		serverErr <- server.Run()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			log.Error().Err(err).Msg("Server error")
+		}
+	case err := <-storageFailed(db):
+		log.Error().Err(err).Msg("Storage failed to start")
+		shutdown(server, cfg)
+	case <-ctx.Done():
+		log.Info().Msg("Shutting down server")
+		shutdown(server, cfg)
+	}
+
+	// The tracer closer is deferred above, so it runs after the storage
+	// is closed and flushes spans of the shutdown as well.
+	if err := db.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to close storage")
+	}
+
+	log.Info().Msg("Server stopped")
+}
+
+func initDB(cfg *config.Config) (dbi.DB, error) {
+	switch cfg.DBDriver {
+	case "mongo":
+		return mongo.New(cfg)
+	case "postgres":
+		return postgres.New(cfg)
+	case "sqlite":
+		return sqlite.New(cfg)
+	case "memory":
+		return memory.New(), nil
+	}
+
+	return nil, fmt.Errorf("unknown db driver %q", cfg.DBDriver)
+}
+
+func shutdown(server *http.Server, cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to drain requests")
+	}
+}
+
+// storageFailed returns the channel of startup errors of storages that
+// connect in the background. It's nil for the others and never ready.
+func storageFailed(db dbi.DB) <-chan error {
+	if s, ok := db.(interface{ Failed() <-chan error }); ok {
+		return s.Failed()
+	}
+
+	return nil
+}
+
+func randomSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
 }
 
 func initTracing() io.Closer {
@@ -51,7 +158,7 @@ func initTracing() io.Closer {
 			LogSpans: true,
 		},
 	}
-	jMetricsFactory := metrics.NullFactory
+	jMetricsFactory := jprom.New()
 	tracer, closer, err := cfg.NewTracer(
 		jaegercfg.Logger(nil),
 		jaegercfg.Metrics(jMetricsFactory),
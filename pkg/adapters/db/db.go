@@ -12,7 +12,13 @@ type (
 		Item
 		User
 		Order
+		Ledger
+		Cart
+		Idempotency
 
+		// Ping reports whether the storage is reachable and ready to
+		// serve requests.
+		Ping(ctx context.Context) error
 		Close() error
 	}
 
@@ -20,20 +26,86 @@ type (
 		AddItem(ctx context.Context, item *domain.AddItemRequest) (string, error)
 		UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error)
 		GetItemById(ctx context.Context, id string) (*domain.Item, error)
-		GetItemsByPrice(ctx context.Context, from, to float64) ([]*domain.Item, error)
-		GetRecentlyAddedItems(ctx context.Context, period time.Duration) ([]*domain.Item, error)
-		GetItemsByOwnerId(ctx context.Context, id string) ([]*domain.Item, error)
+		// FindItems returns a page of items matching the filter. The
+		// page is sorted by the sort key and then by ID, so records with
+		// equal keys can't be skipped or repeated between pages.
+		FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error)
+		// CountItemFacets counts items matching the filter per category
+		// and per price bucket.
+		CountItemFacets(ctx context.Context, filter domain.ItemFilter, buckets domain.PriceBuckets) (*domain.ItemFacets, error)
+		// SearchItems returns up to req.Limit items matching the query,
+		// most relevant first.
+		SearchItems(ctx context.Context, req domain.SearchRequest) ([]*domain.SearchHit, error)
 	}
 
 	User interface {
 		RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (string, error)
 		GetUserById(ctx context.Context, id string) (*domain.User, error)
-		GetRecentlyAddedUsers(ctx context.Context, count int64) ([]*domain.User, error)
+		FindUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error)
+		// GetUserCredentials returns the password hash of the user with
+		// the email, or ErrUserNotFound.
+		GetUserCredentials(ctx context.Context, email string) (*domain.Credentials, error)
+		// AddUserRole and RemoveUserRole grant and revoke a role of the
+		// user. Granting a role the user has or revoking one it hasn't
+		// isn't an error.
+		AddUserRole(ctx context.Context, id string, role domain.Role) error
+		RemoveUserRole(ctx context.Context, id string, role domain.Role) error
 	}
 
 	Order interface {
 		CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (string, error)
 		GetOrderInfo(ctx context.Context, id string) (*domain.Order, error)
+		GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error)
+		// GetUnpaidOrderIds returns IDs of orders still waiting for payment
+		// that were created before the time, oldest first.
+		GetUnpaidOrderIds(ctx context.Context, createdBefore time.Time, limit int64) ([]string, error)
 		UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrderRequest) error
+		TransitionOrder(ctx context.Context, id string, tr domain.StatusTransition) error
+		PayOrder(ctx context.Context, id string, tr domain.StatusTransition) error
+		RefundOrder(ctx context.Context, id string, tr domain.StatusTransition) error
+	}
+
+	// Cart keeps carts of users. Every change bumps the cart version,
+	// clearing included, so versions never repeat.
+	Cart interface {
+		// GetCart returns the cart of the user. Users that never had one
+		// get an empty cart of version 0.
+		GetCart(ctx context.Context, userID string) (*domain.Cart, error)
+		// SetCartItem sets the quantity of the item in the cart, adding
+		// the item if it isn't there yet. The cart is changed only if it
+		// is still of the version, ErrCartChanged is returned otherwise.
+		SetCartItem(ctx context.Context, userID, itemID string, quantity, version int64) error
+		// RemoveCartItem returns ErrCartItemNotFound if the item isn't
+		// in the cart.
+		RemoveCartItem(ctx context.Context, userID, itemID string) error
+		ClearCart(ctx context.Context, userID string) error
+	}
+
+	// Idempotency keeps requests made with idempotency keys along with
+	// their responses. Expired records are treated as if they were gone.
+	Idempotency interface {
+		// ClaimIdempotencyKey records the request unless an unexpired
+		// record with its key exists. It returns that record then, and
+		// nil if the request was recorded.
+		ClaimIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error)
+		// CompleteIdempotencyKey stores the response and the expiry of
+		// the record with the key. Records claimed by requests with
+		// other fingerprints are left as they are.
+		CompleteIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) error
+		// ReleaseIdempotencyKey deletes the record with the key, so the
+		// request can be made again.
+		ReleaseIdempotencyKey(ctx context.Context, key string) error
+		// PurgeIdempotencyKeys deletes expired records. It's called
+		// periodically, so claims look only at the record of their key.
+		PurgeIdempotencyKeys(ctx context.Context) error
+	}
+
+	Ledger interface {
+		PostTransaction(ctx context.Context, req *domain.PostTransactionRequest) (*domain.Transaction, error)
+		// Withdraw debits the amount from the user's balance. It fails
+		// with ErrInsufficientFunds if the balance is short of it.
+		Withdraw(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error)
+		GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error)
+		RecomputeBalance(ctx context.Context, userID string) (domain.Money, error)
 	}
 )
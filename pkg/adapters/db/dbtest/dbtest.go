@@ -0,0 +1,1668 @@
+// Package dbtest is a conformance suite for db.DB implementations.
+// Every storage adapter runs it from its own tests, so all of them
+// behave the same way towards pkg/components.
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+const invalidID = "not-an-id"
+
+type Adapter struct {
+	// New returns an empty database. It's called once per test.
+	New func(t *testing.T) db.DB
+	// UnknownID is a well-formed ID that doesn't belong to any record.
+	UnknownID string
+}
+
+func Run(t *testing.T, a Adapter) {
+	t.Run("Items", func(t *testing.T) { testItems(t, a) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, a) })
+	t.Run("Orders", func(t *testing.T) { testOrders(t, a) })
+	t.Run("Ledger", func(t *testing.T) { testLedger(t, a) })
+	t.Run("Carts", func(t *testing.T) { testCarts(t, a) })
+	t.Run("Idempotency", func(t *testing.T) { testIdempotency(t, a) })
+}
+
+func testItems(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	t.Run("AddAndGet", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		id := mustAddItem(t, d, owner, "2.50", 3)
+
+		it, err := d.GetItemById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetItemById() error = %v", err)
+		}
+
+		if it.ID != id || it.OwnerID != owner || it.Name != "item" || it.Quantity != 3 {
+			t.Errorf("GetItemById() = %+v, want id %s owner %s", it, id, owner)
+		}
+		if it.Price != money(t, "2.50") {
+			t.Errorf("GetItemById() price = %v, want 2.50", it.Price)
+		}
+	})
+
+	t.Run("AddErrors", func(t *testing.T) {
+		d := a.New(t)
+
+		if _, err := d.AddItem(ctx, nil); !errors.Is(err, domain.ErrNoItem) {
+			t.Errorf("AddItem(nil) error = %v, want %v", err, domain.ErrNoItem)
+		}
+
+		_, err := d.AddItem(ctx, &domain.AddItemRequest{OwnerID: invalidID, Name: "item"})
+		if !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("AddItem(invalid owner) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+	})
+
+	t.Run("GetErrors", func(t *testing.T) {
+		d := a.New(t)
+
+		if _, err := d.GetItemById(ctx, invalidID); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("GetItemById(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if _, err := d.GetItemById(ctx, a.UnknownID); !errors.Is(err, domain.ErrItemNotFound) {
+			t.Errorf("GetItemById(unknown) error = %v, want %v", err, domain.ErrItemNotFound)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		id := mustAddItem(t, d, owner, "2.50", 3)
+
+		name := "renamed"
+		price := money(t, "7.25")
+		count, err := d.UpdateItem(ctx, id, &domain.UpdateItemRequest{Name: &name, Price: &price})
+		if err != nil || count != 1 {
+			t.Fatalf("UpdateItem() = %d, %v, want 1, nil", count, err)
+		}
+
+		it := mustGetItem(t, d, id)
+		if it.Name != name || it.Price != price || it.Quantity != 3 {
+			t.Errorf("item after update = %+v, want name %q price %v quantity 3", it, name, price)
+		}
+	})
+
+	t.Run("UpdateOwner", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		buyer := mustRegisterUser(t, d, "buyer")
+		id := mustAddItem(t, d, owner, "2.50", 3)
+
+		count, err := d.UpdateItem(ctx, id, &domain.UpdateItemRequest{OwnerID: &buyer})
+		if err != nil || count != 1 {
+			t.Fatalf("UpdateItem() = %d, %v, want 1, nil", count, err)
+		}
+
+		if it := mustGetItem(t, d, id); it.OwnerID != buyer {
+			t.Errorf("item owner after update = %s, want %s", it.OwnerID, buyer)
+		}
+
+		page, err := d.FindItems(ctx, domain.ItemFilter{OwnerID: buyer}, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems(new owner) error = %v", err)
+		}
+		assertItemIDs(t, page.Items, id)
+
+		page, err = d.FindItems(ctx, domain.ItemFilter{OwnerID: owner}, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems(old owner) error = %v", err)
+		}
+		assertItemIDs(t, page.Items)
+	})
+
+	t.Run("UpdateErrors", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		id := mustAddItem(t, d, owner, "2.50", 3)
+		name := "renamed"
+
+		if _, err := d.UpdateItem(ctx, invalidID, &domain.UpdateItemRequest{Name: &name}); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("UpdateItem(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if _, err := d.UpdateItem(ctx, a.UnknownID, &domain.UpdateItemRequest{Name: &name}); !errors.Is(err, domain.ErrItemNotFound) {
+			t.Errorf("UpdateItem(unknown) error = %v, want %v", err, domain.ErrItemNotFound)
+		}
+		if _, err := d.UpdateItem(ctx, id, nil); !errors.Is(err, domain.ErrNoUpdate) {
+			t.Errorf("UpdateItem(nil) error = %v, want %v", err, domain.ErrNoUpdate)
+		}
+		if _, err := d.UpdateItem(ctx, id, &domain.UpdateItemRequest{}); !errors.Is(err, domain.ErrNoUpdate) {
+			t.Errorf("UpdateItem(empty) error = %v, want %v", err, domain.ErrNoUpdate)
+		}
+	})
+
+	t.Run("FindItemsByPrice", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		mustAddItem(t, d, owner, "1.00", 1)
+		mid := mustAddItem(t, d, owner, "5.00", 1)
+		high := mustAddItem(t, d, owner, "10.00", 1)
+
+		from, to := money(t, "2.00"), money(t, "10.00")
+		page, err := d.FindItems(ctx, domain.ItemFilter{MinPrice: &from, MaxPrice: &to}, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems() error = %v", err)
+		}
+		assertItemIDs(t, page.Items, mid, high)
+
+		usd := domain.NewMoney(1000, "USD")
+		_, err = d.FindItems(ctx, domain.ItemFilter{MinPrice: &from, MaxPrice: &usd}, firstPage(10, domain.SortNewest))
+		if !errors.Is(err, domain.ErrCurrencyMismatch) {
+			t.Errorf("FindItems(mixed currencies) error = %v, want %v", err, domain.ErrCurrencyMismatch)
+		}
+	})
+
+	t.Run("FindItemsCreatedAfter", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		first := mustAddItem(t, d, owner, "1.00", 1)
+		second := mustAddItem(t, d, owner, "2.00", 1)
+
+		filter := domain.ItemFilter{CreatedAfter: time.Now().Add(-time.Hour)}
+		page, err := d.FindItems(ctx, filter, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems() error = %v", err)
+		}
+		assertItemIDs(t, page.Items, first, second)
+
+		filter = domain.ItemFilter{CreatedAfter: time.Now().Add(time.Hour)}
+		page, err = d.FindItems(ctx, filter, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems() error = %v", err)
+		}
+		assertItemIDs(t, page.Items)
+	})
+
+	t.Run("FindItemsByOwner", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		other := mustRegisterUser(t, d, "other")
+		own := mustAddItem(t, d, owner, "1.00", 1)
+		mustAddItem(t, d, other, "2.00", 1)
+
+		page, err := d.FindItems(ctx, domain.ItemFilter{OwnerID: owner}, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems() error = %v", err)
+		}
+		assertItemIDs(t, page.Items, own)
+
+		_, err = d.FindItems(ctx, domain.ItemFilter{OwnerID: invalidID}, firstPage(10, domain.SortNewest))
+		if !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("FindItems(invalid owner) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+	})
+
+	t.Run("FindItemsByCategoryInStock", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		book := mustAddItemIn(t, d, owner, "books", "5.00", 1)
+		mustAddItemIn(t, d, owner, "books", "20.00", 0)
+		game := mustAddItemIn(t, d, owner, "games", "60.00", 1)
+		mustAddItemIn(t, d, owner, "toys", "1.00", 1)
+
+		filter := domain.ItemFilter{Categories: []string{"books", "games"}, InStock: true}
+		page, err := d.FindItems(ctx, filter, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindItems() error = %v", err)
+		}
+		assertItemIDs(t, page.Items, book, game)
+	})
+
+	t.Run("CountItemFacets", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		mustAddItemIn(t, d, owner, "books", "5.00", 1)
+		mustAddItemIn(t, d, owner, "books", "20.00", 0)
+		mustAddItemIn(t, d, owner, "games", "60.00", 1)
+		mustAddItemIn(t, d, owner, "games", "600.00", 1)
+
+		buckets := domain.NewPriceBuckets(domain.DefaultCurrency)
+
+		// Category counts ignore the category filter, price counts
+		// respect it.
+		facets, err := d.CountItemFacets(ctx, domain.ItemFilter{Categories: []string{"books"}}, buckets)
+		if err != nil {
+			t.Fatalf("CountItemFacets() error = %v", err)
+		}
+		assertCategoryCounts(t, facets, domain.CategoryCount{Category: "books", Count: 2}, domain.CategoryCount{Category: "games", Count: 2})
+		assertPriceCounts(t, facets, 1, 1, 0, 0, 0, 0)
+
+		// And the other way around for the price filter.
+		min := money(t, "50.00")
+		facets, err = d.CountItemFacets(ctx, domain.ItemFilter{MinPrice: &min}, buckets)
+		if err != nil {
+			t.Fatalf("CountItemFacets() error = %v", err)
+		}
+		assertCategoryCounts(t, facets, domain.CategoryCount{Category: "games", Count: 2})
+		assertPriceCounts(t, facets, 1, 1, 1, 0, 1, 0)
+
+		facets, err = d.CountItemFacets(ctx, domain.ItemFilter{InStock: true}, domain.NewPriceBuckets("USD"))
+		if err != nil {
+			t.Fatalf("CountItemFacets(USD) error = %v", err)
+		}
+		assertCategoryCounts(t, facets, domain.CategoryCount{Category: "games", Count: 2}, domain.CategoryCount{Category: "books", Count: 1})
+		assertPriceCounts(t, facets, 0, 0, 0, 0, 0, 0)
+	})
+
+	t.Run("FindItemsPages", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+
+		// Equal prices make sure pages don't skip or repeat items with
+		// equal sort keys.
+		var all []string
+		for _, price := range []string{"2.00", "1.00", "2.00", "3.00", "2.00"} {
+			all = append(all, mustAddItem(t, d, owner, price, 1))
+		}
+
+		for _, sort := range domain.ItemSorts {
+			t.Run(string(sort), func(t *testing.T) {
+				var got []*domain.Item
+				page := firstPage(2, sort)
+				for i := 0; ; i++ {
+					if i == len(all) {
+						t.Fatalf("pages don't end")
+					}
+
+					result, err := d.FindItems(ctx, domain.ItemFilter{}, page)
+					if err != nil {
+						t.Fatalf("FindItems() error = %v", err)
+					}
+					if int64(len(result.Items)) > page.Limit {
+						t.Fatalf("FindItems() returned %d items, limit is %d", len(result.Items), page.Limit)
+					}
+					got = append(got, result.Items...)
+
+					if result.NextCursor == "" {
+						break
+					}
+					page.After, err = domain.DecodeCursor(result.NextCursor, sort)
+					if err != nil {
+						t.Fatalf("DecodeCursor() error = %v", err)
+					}
+				}
+
+				assertItemIDs(t, got, all...)
+				for i := 1; i < len(got); i++ {
+					if !inOrder(sort, got[i-1].SortKey(sort), got[i-1].ID, got[i].SortKey(sort), got[i].ID) {
+						t.Errorf("items %s and %s are out of %s order", got[i-1].ID, got[i].ID, sort)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("FindItemsInvalidCursor", func(t *testing.T) {
+		d := a.New(t)
+
+		page := firstPage(10, domain.SortNewest)
+		page.After = &domain.Cursor{Sort: domain.SortNewest, ID: invalidID}
+		if _, err := d.FindItems(ctx, domain.ItemFilter{}, page); !errors.Is(err, domain.ErrInvalidCursor) {
+			t.Errorf("FindItems(invalid cursor) error = %v, want %v", err, domain.ErrInvalidCursor)
+		}
+	})
+
+	t.Run("SearchItems", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		red := mustAddDescribedItem(t, d, owner, "Red phone", "")
+		cases := mustAddDescribedItem(t, d, owner, "Phone case", "Fits every phone")
+		mustAddDescribedItem(t, d, owner, "Table", "Wooden")
+
+		hits, err := d.SearchItems(ctx, domain.SearchRequest{Query: "phone", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchItems() error = %v", err)
+		}
+		if len(hits) != 2 || hits[0].Item.ID != cases || hits[1].Item.ID != red {
+			t.Fatalf("SearchItems(phone) = %v, want [%s %s]", hitIDs(hits), cases, red)
+		}
+		if hits[0].Score <= hits[1].Score {
+			t.Errorf("scores = %v, %v, want descending", hits[0].Score, hits[1].Score)
+		}
+		if got, want := hits[1].Highlights["name"], "Red <em>phone</em>"; got != want {
+			t.Errorf("name highlight = %q, want %q", got, want)
+		}
+
+		hits, err = d.SearchItems(ctx, domain.SearchRequest{Query: "phone", Limit: 1})
+		if err != nil {
+			t.Fatalf("SearchItems(limit 1) error = %v", err)
+		}
+		if len(hits) != 1 || hits[0].Item.ID != cases {
+			t.Errorf("SearchItems(phone, limit 1) = %v, want [%s]", hitIDs(hits), cases)
+		}
+
+		hits, err = d.SearchItems(ctx, domain.SearchRequest{Query: "sofa", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchItems(sofa) error = %v", err)
+		}
+		if len(hits) != 0 {
+			t.Errorf("SearchItems(sofa) = %v, want none", hitIDs(hits))
+		}
+	})
+
+	t.Run("SearchEscapesHighlights", func(t *testing.T) {
+		d := a.New(t)
+		owner := mustRegisterUser(t, d, "owner")
+		id := mustAddDescribedItem(t, d, owner, `<script>alert("x")</script> lamp`, "<img src=x onerror=alert(1)> lamp & shade")
+
+		hits, err := d.SearchItems(ctx, domain.SearchRequest{Query: "lamp", Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchItems() error = %v", err)
+		}
+		if len(hits) != 1 || hits[0].Item.ID != id {
+			t.Fatalf("SearchItems(lamp) = %v, want [%s]", hitIDs(hits), id)
+		}
+
+		want := map[string]string{
+			"name": "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <em>lamp</em>",
+			"desc": "&lt;img src=x onerror=alert(1)&gt; <em>lamp</em> &amp; shade",
+		}
+		for field, w := range want {
+			if got := hits[0].Highlights[field]; got != w {
+				t.Errorf("%s highlight = %q, want %q", field, got, w)
+			}
+		}
+	})
+}
+
+func testUsers(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	t.Run("RegisterAndGet", func(t *testing.T) {
+		d := a.New(t)
+		id, err := d.RegisterUser(ctx, &domain.RegisterUserRequest{Name: "user", Email: "user@example.com", Phone: "123"})
+		if err != nil {
+			t.Fatalf("RegisterUser() error = %v", err)
+		}
+
+		user, err := d.GetUserById(ctx, id)
+		if err != nil {
+			t.Fatalf("GetUserById() error = %v", err)
+		}
+
+		if user.ID != id || user.Name != "user" || user.Email != "user@example.com" || user.Phone != "123" {
+			t.Errorf("GetUserById() = %+v", user)
+		}
+		if user.Balance != domain.NewMoney(0, domain.DefaultCurrency) {
+			t.Errorf("new user balance = %v, want 0.00 %s", user.Balance, domain.DefaultCurrency)
+		}
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		d := a.New(t)
+		mustRegisterUser(t, d, "user")
+
+		_, err := d.RegisterUser(ctx, &domain.RegisterUserRequest{Name: "other", Email: "user@example.com"})
+		if !errors.Is(err, domain.ErrEmailTaken) {
+			t.Errorf("RegisterUser(taken email) error = %v, want %v", err, domain.ErrEmailTaken)
+		}
+	})
+
+	t.Run("GetErrors", func(t *testing.T) {
+		d := a.New(t)
+
+		if _, err := d.GetUserById(ctx, invalidID); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("GetUserById(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if _, err := d.GetUserById(ctx, a.UnknownID); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("GetUserById(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+	})
+
+	t.Run("FindUsers", func(t *testing.T) {
+		d := a.New(t)
+		first := mustRegisterUser(t, d, "first")
+		second := mustRegisterUser(t, d, "second")
+		third := mustRegisterUser(t, d, "third")
+
+		page, err := d.FindUsers(ctx, firstPage(2, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("FindUsers() error = %v", err)
+		}
+		if ids := userIDs(page.Users); len(ids) != 2 || ids[0] != third || ids[1] != second {
+			t.Errorf("FindUsers(limit 2) = %v, want [%s %s]", ids, third, second)
+		}
+		if page.NextCursor == "" {
+			t.Fatalf("FindUsers(limit 2) returned no cursor")
+		}
+
+		after, err := domain.DecodeCursor(page.NextCursor, domain.SortNewest)
+		if err != nil {
+			t.Fatalf("DecodeCursor() error = %v", err)
+		}
+
+		page, err = d.FindUsers(ctx, domain.PageRequest{Limit: 2, Sort: domain.SortNewest, After: after})
+		if err != nil {
+			t.Fatalf("FindUsers(next page) error = %v", err)
+		}
+		if ids := userIDs(page.Users); len(ids) != 1 || ids[0] != first {
+			t.Errorf("FindUsers(next page) = %v, want [%s]", ids, first)
+		}
+		if page.NextCursor != "" {
+			t.Errorf("last page cursor = %q, want none", page.NextCursor)
+		}
+	})
+
+	t.Run("GetUserCredentials", func(t *testing.T) {
+		d := a.New(t)
+		hash := []byte("$2a$10$hashofthepassword")
+		id, err := d.RegisterUser(ctx, &domain.RegisterUserRequest{
+			Name:         "user",
+			Email:        "user@example.com",
+			PasswordHash: hash,
+		})
+		if err != nil {
+			t.Fatalf("RegisterUser() error = %v", err)
+		}
+		mustRegisterUser(t, d, "other")
+
+		creds, err := d.GetUserCredentials(ctx, "user@example.com")
+		if err != nil {
+			t.Fatalf("GetUserCredentials() error = %v", err)
+		}
+		if creds.UserID != id || string(creds.PasswordHash) != string(hash) {
+			t.Errorf("GetUserCredentials() = {%s %q}, want {%s %q}", creds.UserID, creds.PasswordHash, id, hash)
+		}
+
+		if _, err := d.GetUserCredentials(ctx, "unknown@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("GetUserCredentials(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+	})
+
+	t.Run("Roles", func(t *testing.T) {
+		d := a.New(t)
+		id := mustRegisterUser(t, d, "user")
+		other := mustRegisterUser(t, d, "other")
+
+		assertRoles(t, d, id)
+
+		for _, role := range []domain.Role{domain.RoleStaff, domain.RoleSeller, domain.RoleStaff} {
+			if err := d.AddUserRole(ctx, id, role); err != nil {
+				t.Fatalf("AddUserRole(%s) error = %v", role, err)
+			}
+		}
+		assertRoles(t, d, id, domain.RoleSeller, domain.RoleStaff)
+		assertRoles(t, d, other)
+
+		page, err := d.FindUsers(ctx, firstPage(10, domain.SortOldest))
+		if err != nil {
+			t.Fatalf("FindUsers() error = %v", err)
+		}
+		if roles := page.Users[0].Roles; !reflect.DeepEqual(roles, []domain.Role{domain.RoleSeller, domain.RoleStaff}) {
+			t.Errorf("FindUsers() roles = %v, want [seller staff]", roles)
+		}
+
+		for _, role := range []domain.Role{domain.RoleSeller, domain.RoleAdmin} {
+			if err := d.RemoveUserRole(ctx, id, role); err != nil {
+				t.Fatalf("RemoveUserRole(%s) error = %v", role, err)
+			}
+		}
+		assertRoles(t, d, id, domain.RoleStaff)
+
+		if err := d.AddUserRole(ctx, a.UnknownID, domain.RoleAdmin); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("AddUserRole(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+		if err := d.RemoveUserRole(ctx, a.UnknownID, domain.RoleAdmin); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("RemoveUserRole(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+		if err := d.AddUserRole(ctx, invalidID, domain.RoleAdmin); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("AddUserRole(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+	})
+}
+
+func testOrders(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	t.Run("Create", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		first := mustAddItem(t, d, seller, "2.50", 5)
+		second := mustAddItem(t, d, seller, "4.00", 1)
+
+		id := mustCreateOrder(t, d, customer, line(first, 2), line(second, 1))
+
+		order, err := d.GetOrderInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+
+		if order.Total != money(t, "9.00") {
+			t.Errorf("order total = %v, want 9.00", order.Total)
+		}
+		if order.Status != domain.CREATED || order.CustomerID != customer || len(order.Items) != 2 {
+			t.Errorf("GetOrderInfo() = %+v", order)
+		}
+		for _, line := range order.Items {
+			if line.SellerID != seller {
+				t.Errorf("order line %s seller = %q, want %q", line.ID, line.SellerID, seller)
+			}
+		}
+
+		if it := mustGetItem(t, d, first); it.Quantity != 3 {
+			t.Errorf("stock after order = %d, want 3", it.Quantity)
+		}
+		if it := mustGetItem(t, d, second); it.Quantity != 0 {
+			t.Errorf("stock after order = %d, want 0", it.Quantity)
+		}
+	})
+
+	t.Run("CreateDuplicateLines", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "2.50", 10)
+
+		id := mustCreateOrder(t, d, customer, line(item, 2), line(item, 3))
+
+		order, err := d.GetOrderInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+		if order.Total != money(t, "12.50") {
+			t.Errorf("order total = %v, want 12.50", order.Total)
+		}
+		if it := mustGetItem(t, d, item); it.Quantity != 5 {
+			t.Errorf("stock after order = %d, want 5", it.Quantity)
+		}
+
+		mustTopUp(t, d, customer, "12.50")
+
+		pay := domain.NewStatusTransition(domain.CREATED, domain.PAID, domain.ActorCustomer)
+		if err := d.PayOrder(ctx, id, pay); err != nil {
+			t.Fatalf("PayOrder() error = %v", err)
+		}
+		assertBalance(t, d, customer, "0.00")
+		assertBalance(t, d, seller, "12.50")
+	})
+
+	t.Run("CreateInsufficientStock", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		plenty := mustAddItem(t, d, seller, "1.00", 10)
+		scarce := mustAddItem(t, d, seller, "1.00", 1)
+
+		_, err := d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID: customer,
+			Items:      []domain.OrderItem{line(plenty, 1), line(scarce, 2)},
+		})
+		if !errors.Is(err, domain.ErrInsufficientStock) {
+			t.Fatalf("CreateOrder() error = %v, want %v", err, domain.ErrInsufficientStock)
+		}
+
+		if it := mustGetItem(t, d, plenty); it.Quantity != 10 {
+			t.Errorf("stock after failed order = %d, want 10", it.Quantity)
+		}
+
+		orders, err := d.GetOrdersByCustomerId(ctx, customer, firstPage(10, domain.SortNewest))
+		if err != nil {
+			t.Fatalf("GetOrdersByCustomerId() error = %v", err)
+		}
+		if len(orders.Orders) != 0 {
+			t.Errorf("GetOrdersByCustomerId() = %d orders, want none", len(orders.Orders))
+		}
+	})
+
+	t.Run("CreateTotalOverflow", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		pricey := mustAddItem(t, d, seller, "50000000000000000.00", 10)
+
+		_, err := d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID: customer,
+			Items:      []domain.OrderItem{line(pricey, 2)},
+		})
+		if !errors.Is(err, domain.ErrMoneyOverflow) {
+			t.Fatalf("CreateOrder() error = %v, want %v", err, domain.ErrMoneyOverflow)
+		}
+
+		if it := mustGetItem(t, d, pricey); it.Quantity != 10 {
+			t.Errorf("stock after failed order = %d, want 10", it.Quantity)
+		}
+	})
+
+	t.Run("CreateErrors", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		if _, err := d.CreateOrder(ctx, nil); !errors.Is(err, domain.ErrNoOrder) {
+			t.Errorf("CreateOrder(nil) error = %v, want %v", err, domain.ErrNoOrder)
+		}
+
+		_, err := d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID: invalidID,
+			Items:      []domain.OrderItem{line(item, 1)},
+		})
+		if !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("CreateOrder(invalid customer) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+
+		_, err = d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID: customer,
+			Items:      []domain.OrderItem{line(invalidID, 1)},
+		})
+		if !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("CreateOrder(invalid item) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+	})
+
+	t.Run("GetErrors", func(t *testing.T) {
+		d := a.New(t)
+
+		if _, err := d.GetOrderInfo(ctx, invalidID); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("GetOrderInfo(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if _, err := d.GetOrderInfo(ctx, a.UnknownID); !errors.Is(err, domain.ErrOrderNotFound) {
+			t.Errorf("GetOrderInfo(unknown) error = %v, want %v", err, domain.ErrOrderNotFound)
+		}
+		if _, err := d.GetOrdersByCustomerId(ctx, invalidID, firstPage(10, domain.SortNewest)); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("GetOrdersByCustomerId(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+
+		page := firstPage(10, domain.SortNewest)
+		page.After = &domain.Cursor{Sort: domain.SortNewest, ID: invalidID}
+		if _, err := d.GetOrdersByCustomerId(ctx, a.UnknownID, page); !errors.Is(err, domain.ErrInvalidCursor) {
+			t.Errorf("GetOrdersByCustomerId(invalid cursor) error = %v, want %v", err, domain.ErrInvalidCursor)
+		}
+	})
+
+	t.Run("GetOrdersByCustomerId", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		other := mustRegisterUser(t, d, "other")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		var all []string
+		for i := 0; i < 3; i++ {
+			all = append(all, mustCreateOrder(t, d, customer, line(item, 1)))
+		}
+		mustCreateOrder(t, d, other, line(item, 1))
+
+		for _, sort := range domain.OrderSorts {
+			t.Run(string(sort), func(t *testing.T) {
+				var got []*domain.Order
+				page := firstPage(2, sort)
+				for i := 0; ; i++ {
+					if i == len(all) {
+						t.Fatalf("pages don't end")
+					}
+
+					result, err := d.GetOrdersByCustomerId(ctx, customer, page)
+					if err != nil {
+						t.Fatalf("GetOrdersByCustomerId() error = %v", err)
+					}
+					if int64(len(result.Orders)) > page.Limit {
+						t.Fatalf("GetOrdersByCustomerId() returned %d orders, limit is %d", len(result.Orders), page.Limit)
+					}
+					got = append(got, result.Orders...)
+
+					if result.NextCursor == "" {
+						break
+					}
+					page.After, err = domain.DecodeCursor(result.NextCursor, sort)
+					if err != nil {
+						t.Fatalf("DecodeCursor() error = %v", err)
+					}
+				}
+
+				ids := make([]string, 0, len(got))
+				for _, ord := range got {
+					ids = append(ids, ord.ID)
+					if len(ord.Items) != 1 {
+						t.Errorf("order %s has %d lines, want 1", ord.ID, len(ord.Items))
+					}
+				}
+				assertSameIDs(t, "GetOrdersByCustomerId()", ids, all)
+
+				for i := 1; i < len(got); i++ {
+					if !inOrder(sort, got[i-1].SortKey(sort), got[i-1].ID, got[i].SortKey(sort), got[i].ID) {
+						t.Errorf("orders %s and %s are out of %s order", got[i-1].ID, got[i].ID, sort)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		first := mustAddItem(t, d, seller, "1.00", 10)
+		second := mustAddItem(t, d, seller, "2.00", 10)
+		id := mustCreateOrder(t, d, customer, line(first, 1))
+
+		items := []domain.OrderItem{line(second, 4)}
+		if err := d.UpdateOrder(ctx, id, domain.UpdateOrderRequest{Items: &items}); err != nil {
+			t.Fatalf("UpdateOrder() error = %v", err)
+		}
+
+		order, err := d.GetOrderInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+		if len(order.Items) != 1 || order.Items[0].ID != second || order.Items[0].Quantity != 4 {
+			t.Errorf("order items after update = %+v, want %+v", order.Items, items)
+		}
+
+		status := domain.CREATED
+		err = d.UpdateOrder(ctx, a.UnknownID, domain.UpdateOrderRequest{Status: &status})
+		if !errors.Is(err, domain.ErrOrderNotFound) {
+			t.Errorf("UpdateOrder(unknown) error = %v, want %v", err, domain.ErrOrderNotFound)
+		}
+		if err := d.UpdateOrder(ctx, id, domain.UpdateOrderRequest{}); !errors.Is(err, domain.ErrNoUpdate) {
+			t.Errorf("UpdateOrder(empty) error = %v, want %v", err, domain.ErrNoUpdate)
+		}
+	})
+
+	t.Run("Transition", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+		id := mustCreateOrder(t, d, customer, line(item, 1))
+
+		tr := domain.NewStatusTransition(domain.CREATED, domain.CANCELLED, domain.ActorCustomer)
+		if err := d.TransitionOrder(ctx, id, tr); err != nil {
+			t.Fatalf("TransitionOrder() error = %v", err)
+		}
+
+		// The order has left CREATED, so the same transition must lose.
+		if err := d.TransitionOrder(ctx, id, tr); !errors.Is(err, domain.ErrOrderStatusConflict) {
+			t.Errorf("repeated TransitionOrder() error = %v, want %v", err, domain.ErrOrderStatusConflict)
+		}
+		if err := d.TransitionOrder(ctx, a.UnknownID, tr); !errors.Is(err, domain.ErrOrderNotFound) {
+			t.Errorf("TransitionOrder(unknown) error = %v, want %v", err, domain.ErrOrderNotFound)
+		}
+
+		order, err := d.GetOrderInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+		if order.Status != domain.CANCELLED || len(order.Transitions) != 1 {
+			t.Fatalf("order after transition = %+v", order)
+		}
+		if got := order.Transitions[0]; got.From != tr.From || got.To != tr.To || got.Actor != tr.Actor {
+			t.Errorf("recorded transition = %+v, want %+v", got, tr)
+		}
+	})
+
+	t.Run("UnpaidOrders", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+		start := time.Now().Add(-time.Second)
+
+		first := mustCreateOrder(t, d, customer, line(item, 1))
+		paid := mustCreateOrder(t, d, customer, line(item, 1))
+		cancelled := mustCreateOrder(t, d, customer, line(item, 1))
+		second := mustCreateOrder(t, d, customer, line(item, 1))
+
+		mustTopUp(t, d, customer, "1.00")
+		if err := d.PayOrder(ctx, paid, domain.NewStatusTransition(domain.CREATED, domain.PAID, domain.ActorCustomer)); err != nil {
+			t.Fatalf("PayOrder() error = %v", err)
+		}
+		if err := d.TransitionOrder(ctx, cancelled, domain.NewStatusTransition(domain.CREATED, domain.CANCELLED, domain.ActorCustomer)); err != nil {
+			t.Fatalf("TransitionOrder() error = %v", err)
+		}
+
+		ids, err := d.GetUnpaidOrderIds(ctx, time.Now().Add(time.Second), 10)
+		if err != nil {
+			t.Fatalf("GetUnpaidOrderIds() error = %v", err)
+		}
+		sort.Strings(ids)
+		want := []string{first, second}
+		sort.Strings(want)
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("GetUnpaidOrderIds() = %v, want %v", ids, want)
+		}
+
+		if ids, err := d.GetUnpaidOrderIds(ctx, time.Now().Add(time.Second), 1); err != nil || len(ids) != 1 {
+			t.Errorf("GetUnpaidOrderIds(limit 1) = %v, %v, want one ID", ids, err)
+		}
+		if ids, err := d.GetUnpaidOrderIds(ctx, start, 10); err != nil || len(ids) != 0 {
+			t.Errorf("GetUnpaidOrderIds(before orders) = %v, %v, want none", ids, err)
+		}
+	})
+
+	t.Run("TransitionReleasesStock", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		for _, to := range []domain.StatusID{domain.CANCELLED, domain.EXPIRED} {
+			id := mustCreateOrder(t, d, customer, line(item, 2), line(item, 3))
+			if it := mustGetItem(t, d, item); it.Quantity != 5 {
+				t.Fatalf("stock after order = %d, want 5", it.Quantity)
+			}
+
+			tr := domain.NewStatusTransition(domain.CREATED, to, domain.ActorSystem)
+			if err := d.TransitionOrder(ctx, id, tr); err != nil {
+				t.Fatalf("TransitionOrder(%s) error = %v", to, err)
+			}
+			if it := mustGetItem(t, d, item); it.Quantity != 10 {
+				t.Errorf("stock after %s = %d, want 10", to, it.Quantity)
+			}
+
+			// A lost transition must not return the stock again.
+			if err := d.TransitionOrder(ctx, id, tr); !errors.Is(err, domain.ErrOrderStatusConflict) {
+				t.Errorf("repeated TransitionOrder(%s) error = %v, want %v", to, err, domain.ErrOrderStatusConflict)
+			}
+			if it := mustGetItem(t, d, item); it.Quantity != 10 {
+				t.Errorf("stock after repeated %s = %d, want 10", to, it.Quantity)
+			}
+		}
+
+		// Stock of paid orders is gone for good.
+		id := mustCreateOrder(t, d, customer, line(item, 4))
+		mustTopUp(t, d, customer, "4.00")
+
+		pay := domain.NewStatusTransition(domain.CREATED, domain.PAID, domain.ActorCustomer)
+		if err := d.PayOrder(ctx, id, pay); err != nil {
+			t.Fatalf("PayOrder() error = %v", err)
+		}
+
+		ship := domain.NewStatusTransition(domain.PAID, domain.SHIPPED, domain.ActorStaff)
+		if err := d.TransitionOrder(ctx, id, ship); err != nil {
+			t.Fatalf("TransitionOrder(shipped) error = %v", err)
+		}
+		if it := mustGetItem(t, d, item); it.Quantity != 6 {
+			t.Errorf("stock after shipping = %d, want 6", it.Quantity)
+		}
+	})
+
+	t.Run("PayAndRefund", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		customer := mustRegisterUser(t, d, "customer")
+		item := mustAddItem(t, d, seller, "2.50", 10)
+		id := mustCreateOrder(t, d, customer, line(item, 2))
+
+		pay := domain.NewStatusTransition(domain.CREATED, domain.PAID, domain.ActorCustomer)
+		if err := d.PayOrder(ctx, id, pay); !errors.Is(err, domain.ErrInsufficientFunds) {
+			t.Fatalf("PayOrder() with empty balance error = %v, want %v", err, domain.ErrInsufficientFunds)
+		}
+		if order, _ := d.GetOrderInfo(ctx, id); order == nil || order.Status != domain.CREATED {
+			t.Fatalf("order after failed payment = %+v, want status %s", order, domain.CREATED)
+		}
+
+		mustTopUp(t, d, customer, "6.00")
+
+		if err := d.PayOrder(ctx, id, pay); err != nil {
+			t.Fatalf("PayOrder() error = %v", err)
+		}
+		assertBalance(t, d, customer, "1.00")
+		assertBalance(t, d, seller, "5.00")
+
+		if err := d.PayOrder(ctx, id, pay); !errors.Is(err, domain.ErrOrderStatusConflict) {
+			t.Errorf("repeated PayOrder() error = %v, want %v", err, domain.ErrOrderStatusConflict)
+		}
+		assertBalance(t, d, customer, "1.00")
+
+		refund := domain.NewStatusTransition(domain.PAID, domain.REFUNDED, domain.ActorStaff)
+		if err := d.RefundOrder(ctx, id, refund); err != nil {
+			t.Fatalf("RefundOrder() error = %v", err)
+		}
+		assertBalance(t, d, customer, "6.00")
+		assertBalance(t, d, seller, "0.00")
+
+		order, err := d.GetOrderInfo(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOrderInfo() error = %v", err)
+		}
+		if order.Status != domain.REFUNDED || len(order.Transitions) != 2 {
+			t.Errorf("order after refund = %+v", order)
+		}
+	})
+}
+
+func testLedger(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	t.Run("PostErrors", func(t *testing.T) {
+		d := a.New(t)
+
+		_, err := d.PostTransaction(ctx, &domain.PostTransactionRequest{UserID: invalidID, Kind: domain.TOPUP, Amount: money(t, "1")})
+		if !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("PostTransaction(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+
+		_, err = d.PostTransaction(ctx, &domain.PostTransactionRequest{UserID: a.UnknownID, Kind: domain.TOPUP, Amount: money(t, "1")})
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("PostTransaction(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+	})
+
+	t.Run("BalanceOverflow", func(t *testing.T) {
+		d := a.New(t)
+		user := mustRegisterUser(t, d, "user")
+		mustTopUp(t, d, user, "92233720368547758.00")
+
+		_, err := d.PostTransaction(ctx, &domain.PostTransactionRequest{UserID: user, Kind: domain.TOPUP, Amount: money(t, "0.08")})
+		if !errors.Is(err, domain.ErrMoneyOverflow) {
+			t.Errorf("PostTransaction() over max balance error = %v, want %v", err, domain.ErrMoneyOverflow)
+		}
+		assertBalance(t, d, user, "92233720368547758.00")
+
+		mustTopUp(t, d, user, "0.07")
+		assertBalance(t, d, user, "92233720368547758.07")
+	})
+
+	t.Run("Withdraw", func(t *testing.T) {
+		d := a.New(t)
+		user := mustRegisterUser(t, d, "user")
+		mustTopUp(t, d, user, "5.00")
+
+		tx, err := d.Withdraw(ctx, user, money(t, "2.00"))
+		if err != nil {
+			t.Fatalf("Withdraw() error = %v", err)
+		}
+		if tx.UserID != user || tx.Kind != domain.WITHDRAWAL || tx.Amount != money(t, "-2.00") {
+			t.Errorf("Withdraw() = %+v", tx)
+		}
+		assertBalance(t, d, user, "3.00")
+
+		if _, err := d.Withdraw(ctx, user, money(t, "3.01")); !errors.Is(err, domain.ErrInsufficientFunds) {
+			t.Errorf("Withdraw() over balance error = %v, want %v", err, domain.ErrInsufficientFunds)
+		}
+		assertBalance(t, d, user, "3.00")
+
+		if _, err := d.Withdraw(ctx, user, money(t, "3.00")); err != nil {
+			t.Fatalf("Withdraw() of whole balance error = %v", err)
+		}
+		assertBalance(t, d, user, "0.00")
+
+		balance, err := d.RecomputeBalance(ctx, user)
+		if err != nil || !balance.IsZero() {
+			t.Errorf("RecomputeBalance() = %v, %v, want 0.00", balance, err)
+		}
+
+		if _, err := d.Withdraw(ctx, invalidID, money(t, "1")); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("Withdraw(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+
+		if _, err := d.Withdraw(ctx, a.UnknownID, money(t, "1")); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("Withdraw(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		d := a.New(t)
+		user := mustRegisterUser(t, d, "user")
+
+		var all []string
+		for _, amount := range []string{"1.00", "2.00", "3.00"} {
+			all = append(all, mustTopUp(t, d, user, amount).ID)
+		}
+		assertBalance(t, d, user, "6.00")
+		mustTopUp(t, d, mustRegisterUser(t, d, "other"), "5.00")
+
+		for _, sort := range domain.TransactionSorts {
+			t.Run(string(sort), func(t *testing.T) {
+				var got []*domain.Transaction
+				page := firstPage(2, sort)
+				for i := 0; ; i++ {
+					if i == len(all) {
+						t.Fatalf("pages don't end")
+					}
+
+					result, err := d.GetTransactions(ctx, user, page)
+					if err != nil {
+						t.Fatalf("GetTransactions() error = %v", err)
+					}
+					if int64(len(result.Transactions)) > page.Limit {
+						t.Fatalf("GetTransactions() returned %d entries, limit is %d", len(result.Transactions), page.Limit)
+					}
+					got = append(got, result.Transactions...)
+
+					if result.NextCursor == "" {
+						break
+					}
+					page.After, err = domain.DecodeCursor(result.NextCursor, sort)
+					if err != nil {
+						t.Fatalf("DecodeCursor() error = %v", err)
+					}
+				}
+
+				ids := make([]string, 0, len(got))
+				for _, tx := range got {
+					ids = append(ids, tx.ID)
+					if tx.UserID != user || tx.Kind != domain.TOPUP {
+						t.Errorf("unexpected ledger entry %+v", tx)
+					}
+				}
+				assertSameIDs(t, "GetTransactions()", ids, all)
+
+				for i := 1; i < len(got); i++ {
+					if !inOrder(sort, got[i-1].SortKey(sort), got[i-1].ID, got[i].SortKey(sort), got[i].ID) {
+						t.Errorf("entries %s and %s are out of %s order", got[i-1].ID, got[i].ID, sort)
+					}
+				}
+			})
+		}
+
+		page := firstPage(10, domain.SortNewest)
+		page.After = &domain.Cursor{Sort: domain.SortNewest, ID: invalidID}
+		if _, err := d.GetTransactions(ctx, user, page); !errors.Is(err, domain.ErrInvalidCursor) {
+			t.Errorf("GetTransactions(invalid cursor) error = %v, want %v", err, domain.ErrInvalidCursor)
+		}
+	})
+
+	t.Run("RecomputeBalance", func(t *testing.T) {
+		d := a.New(t)
+		user := mustRegisterUser(t, d, "user")
+		mustTopUp(t, d, user, "4.20")
+		mustTopUp(t, d, user, "0.80")
+
+		balance, err := d.RecomputeBalance(ctx, user)
+		if err != nil {
+			t.Fatalf("RecomputeBalance() error = %v", err)
+		}
+		if balance != money(t, "5.00") {
+			t.Errorf("RecomputeBalance() = %v, want 5.00", balance)
+		}
+
+		if _, err := d.RecomputeBalance(ctx, a.UnknownID); !errors.Is(err, domain.ErrUserNotFound) {
+			t.Errorf("RecomputeBalance(unknown) error = %v, want %v", err, domain.ErrUserNotFound)
+		}
+	})
+}
+
+func testCarts(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	t.Run("Empty", func(t *testing.T) {
+		d := a.New(t)
+		user := mustRegisterUser(t, d, "user")
+
+		cart := mustGetCart(t, d, user)
+		if cart.UserID != user || len(cart.Items) != 0 || cart.Version != 0 {
+			t.Errorf("GetCart() = %+v, want empty cart of version 0", cart)
+		}
+	})
+
+	t.Run("SetAndRemove", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		first := mustAddItem(t, d, seller, "1.00", 10)
+		second := mustAddItem(t, d, seller, "2.00", 10)
+
+		mustSetCartItem(t, d, user, first, 1)
+		mustSetCartItem(t, d, user, second, 2)
+		mustSetCartItem(t, d, user, first, 3)
+
+		cart := mustGetCart(t, d, user)
+		assertCartItems(t, cart, line(first, 3), line(second, 2))
+		if cart.Version != 3 {
+			t.Errorf("cart version = %d, want 3", cart.Version)
+		}
+
+		if err := d.RemoveCartItem(ctx, user, first); err != nil {
+			t.Fatalf("RemoveCartItem() error = %v", err)
+		}
+		if err := d.RemoveCartItem(ctx, user, first); !errors.Is(err, domain.ErrCartItemNotFound) {
+			t.Errorf("RemoveCartItem(removed) error = %v, want %v", err, domain.ErrCartItemNotFound)
+		}
+
+		cart = mustGetCart(t, d, user)
+		assertCartItems(t, cart, line(second, 2))
+		if cart.Version != 4 {
+			t.Errorf("cart version = %d, want 4", cart.Version)
+		}
+	})
+
+	t.Run("SetStale", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		if err := d.SetCartItem(ctx, user, item, 1, 1); !errors.Is(err, domain.ErrCartChanged) {
+			t.Errorf("SetCartItem(no cart yet) error = %v, want %v", err, domain.ErrCartChanged)
+		}
+
+		mustSetCartItem(t, d, user, item, 1)
+		if err := d.SetCartItem(ctx, user, item, 5, 0); !errors.Is(err, domain.ErrCartChanged) {
+			t.Errorf("SetCartItem(new cart over existing) error = %v, want %v", err, domain.ErrCartChanged)
+		}
+
+		cart := mustGetCart(t, d, user)
+		mustSetCartItem(t, d, user, item, 2)
+		if err := d.SetCartItem(ctx, user, item, 5, cart.Version); !errors.Is(err, domain.ErrCartChanged) {
+			t.Errorf("SetCartItem(stale) error = %v, want %v", err, domain.ErrCartChanged)
+		}
+
+		cart = mustGetCart(t, d, user)
+		assertCartItems(t, cart, line(item, 2))
+		if cart.Version != 2 {
+			t.Errorf("cart version = %d, want 2", cart.Version)
+		}
+	})
+
+	t.Run("ConcurrentAdds", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 100)
+
+		// Every writer adds one to the quantity it read, the way
+		// AddToCart does, and retries when the cart changed meanwhile.
+		const writers = 8
+
+		errs := make(chan error, writers)
+		for i := 0; i < writers; i++ {
+			go func() {
+				for {
+					cart, err := d.GetCart(ctx, user)
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					err = d.SetCartItem(ctx, user, item, cart.Quantity(item)+1, cart.Version)
+					if !errors.Is(err, domain.ErrCartChanged) {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < writers; i++ {
+			if err := <-errs; err != nil {
+				t.Fatalf("SetCartItem() error = %v", err)
+			}
+		}
+
+		assertCartItems(t, mustGetCart(t, d, user), line(item, writers))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		mustSetCartItem(t, d, user, item, 1)
+		if err := d.ClearCart(ctx, user); err != nil {
+			t.Fatalf("ClearCart() error = %v", err)
+		}
+
+		cart := mustGetCart(t, d, user)
+		if len(cart.Items) != 0 || cart.Version != 2 {
+			t.Errorf("GetCart() = %+v, want empty cart of version 2", cart)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		if _, err := d.GetCart(ctx, invalidID); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("GetCart(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if err := d.SetCartItem(ctx, user, invalidID, 1, 0); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("SetCartItem(invalid item) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+		if err := d.SetCartItem(ctx, user, item, 0, 0); !errors.Is(err, domain.ErrCartQuantity) {
+			t.Errorf("SetCartItem(quantity 0) error = %v, want %v", err, domain.ErrCartQuantity)
+		}
+		if err := d.RemoveCartItem(ctx, user, item); !errors.Is(err, domain.ErrCartItemNotFound) {
+			t.Errorf("RemoveCartItem(not in cart) error = %v, want %v", err, domain.ErrCartItemNotFound)
+		}
+		if err := d.ClearCart(ctx, invalidID); !errors.Is(err, domain.ErrInvalidId) {
+			t.Errorf("ClearCart(invalid) error = %v, want %v", err, domain.ErrInvalidId)
+		}
+	})
+
+	t.Run("Checkout", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		mustSetCartItem(t, d, user, item, 2)
+		cart := mustGetCart(t, d, user)
+
+		_, err := d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID:  user,
+			Items:       []domain.OrderItem{line(item, 2)},
+			CartVersion: cart.Version,
+		})
+		if err != nil {
+			t.Fatalf("CreateOrder() error = %v", err)
+		}
+
+		after := mustGetCart(t, d, user)
+		if len(after.Items) != 0 || after.Version <= cart.Version {
+			t.Errorf("cart after checkout = %+v, want empty cart newer than %d", after, cart.Version)
+		}
+	})
+
+	t.Run("CheckoutChanged", func(t *testing.T) {
+		d := a.New(t)
+		seller := mustRegisterUser(t, d, "seller")
+		user := mustRegisterUser(t, d, "user")
+		item := mustAddItem(t, d, seller, "1.00", 10)
+
+		mustSetCartItem(t, d, user, item, 2)
+		cart := mustGetCart(t, d, user)
+		mustSetCartItem(t, d, user, item, 5)
+
+		_, err := d.CreateOrder(ctx, &domain.CreateOrderRequest{
+			CustomerID:  user,
+			Items:       []domain.OrderItem{line(item, 2)},
+			CartVersion: cart.Version,
+		})
+		if !errors.Is(err, domain.ErrCartChanged) {
+			t.Fatalf("CreateOrder(stale cart) error = %v, want %v", err, domain.ErrCartChanged)
+		}
+
+		if it := mustGetItem(t, d, item); it.Quantity != 10 {
+			t.Errorf("stock after failed checkout = %d, want 10", it.Quantity)
+		}
+		assertCartItems(t, mustGetCart(t, d, user), line(item, 5))
+	})
+}
+
+func testIdempotency(t *testing.T, a Adapter) {
+	ctx := context.Background()
+
+	claim := func(t *testing.T, d db.DB, key, fingerprint string, ttl time.Duration) *domain.IdempotencyRecord {
+		t.Helper()
+
+		stored, err := d.ClaimIdempotencyKey(ctx, &domain.IdempotencyRecord{
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().Add(ttl),
+		})
+		if err != nil {
+			t.Fatalf("ClaimIdempotencyKey() error = %v", err)
+		}
+
+		return stored
+	}
+
+	t.Run("ClaimAndComplete", func(t *testing.T) {
+		d := a.New(t)
+
+		if stored := claim(t, d, "user:key", "first", time.Hour); stored != nil {
+			t.Fatalf("ClaimIdempotencyKey(new) = %+v, want nil", stored)
+		}
+
+		stored := claim(t, d, "user:key", "second", time.Hour)
+		if stored == nil || stored.Fingerprint != "first" || stored.Response != nil {
+			t.Fatalf("ClaimIdempotencyKey(claimed) = %+v, want record of first without response", stored)
+		}
+
+		resp := &domain.IdempotentResponse{Status: 200, ContentType: "application/json", Body: []byte(`"id"`)}
+
+		err := d.CompleteIdempotencyKey(ctx, &domain.IdempotencyRecord{
+			Key:         "user:key",
+			Fingerprint: "other",
+			Response:    resp,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("CompleteIdempotencyKey(other fingerprint) error = %v", err)
+		}
+		if stored := claim(t, d, "user:key", "first", time.Hour); stored == nil || stored.Response != nil {
+			t.Fatalf("record completed by other fingerprint = %+v, want no response", stored)
+		}
+
+		err = d.CompleteIdempotencyKey(ctx, &domain.IdempotencyRecord{
+			Key:         "user:key",
+			Fingerprint: "first",
+			Response:    resp,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatalf("CompleteIdempotencyKey() error = %v", err)
+		}
+
+		stored = claim(t, d, "user:key", "first", time.Hour)
+		if stored == nil || stored.Response == nil || !reflect.DeepEqual(*stored.Response, *resp) {
+			t.Fatalf("ClaimIdempotencyKey(completed) = %+v, want response %+v", stored, resp)
+		}
+
+		if stored := claim(t, d, "other:key", "first", time.Hour); stored != nil {
+			t.Errorf("ClaimIdempotencyKey(other key) = %+v, want nil", stored)
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		d := a.New(t)
+
+		claim(t, d, "user:key", "first", time.Hour)
+		if err := d.ReleaseIdempotencyKey(ctx, "user:key"); err != nil {
+			t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+		}
+
+		if stored := claim(t, d, "user:key", "second", time.Hour); stored != nil {
+			t.Errorf("ClaimIdempotencyKey(released) = %+v, want nil", stored)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		d := a.New(t)
+
+		claim(t, d, "user:key", "first", -time.Second)
+
+		if stored := claim(t, d, "user:key", "second", time.Hour); stored != nil {
+			t.Errorf("ClaimIdempotencyKey(expired) = %+v, want nil", stored)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		d := a.New(t)
+
+		claim(t, d, "user:old", "first", -time.Second)
+		claim(t, d, "user:new", "first", time.Hour)
+
+		if err := d.PurgeIdempotencyKeys(ctx); err != nil {
+			t.Fatalf("PurgeIdempotencyKeys() error = %v", err)
+		}
+
+		if stored := claim(t, d, "user:old", "second", time.Hour); stored != nil {
+			t.Errorf("ClaimIdempotencyKey(purged) = %+v, want nil", stored)
+		}
+
+		stored := claim(t, d, "user:new", "second", time.Hour)
+		if stored == nil || stored.Fingerprint != "first" {
+			t.Errorf("ClaimIdempotencyKey(live) = %+v, want the stored record", stored)
+		}
+	})
+}
+
+func firstPage(limit int64, sort domain.SortOrder) domain.PageRequest {
+	return domain.PageRequest{Limit: limit, Sort: sort}
+}
+
+// inOrder reports whether records with keys a and b and IDs idA and idB
+// are sorted in sort order.
+func inOrder(sort domain.SortOrder, a int64, idA string, b int64, idB string) bool {
+	if sort.Descending() {
+		a, idA, b, idB = b, idB, a, idA
+	}
+
+	return a < b || a == b && idA < idB
+}
+
+func money(t *testing.T, s string) domain.Money {
+	t.Helper()
+
+	m, err := domain.ParseMoney(s, domain.DefaultCurrency)
+	if err != nil {
+		t.Fatalf("ParseMoney(%q) error = %v", s, err)
+	}
+
+	return m
+}
+
+func mustRegisterUser(t *testing.T, d db.DB, name string) string {
+	t.Helper()
+
+	id, err := d.RegisterUser(context.Background(), &domain.RegisterUserRequest{
+		Name:  name,
+		Email: name + "@example.com",
+	})
+	if err != nil {
+		t.Fatalf("RegisterUser() error = %v", err)
+	}
+
+	return id
+}
+
+func assertRoles(t *testing.T, d db.DB, id string, want ...domain.Role) {
+	t.Helper()
+
+	user, err := d.GetUserById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUserById() error = %v", err)
+	}
+
+	if want == nil {
+		want = []domain.Role{}
+	}
+	if !reflect.DeepEqual(user.Roles, want) {
+		t.Errorf("user roles = %v, want %v", user.Roles, want)
+	}
+}
+
+func mustAddItem(t *testing.T, d db.DB, owner, price string, quantity uint64) string {
+	t.Helper()
+
+	id, err := d.AddItem(context.Background(), &domain.AddItemRequest{
+		OwnerID:  owner,
+		Name:     "item",
+		Category: "misc",
+		Price:    money(t, price),
+		Quantity: quantity,
+	})
+	if err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	return id
+}
+
+func mustAddItemIn(t *testing.T, d db.DB, owner, category, price string, quantity uint64) string {
+	t.Helper()
+
+	id, err := d.AddItem(context.Background(), &domain.AddItemRequest{
+		OwnerID:  owner,
+		Name:     "item",
+		Category: category,
+		Price:    money(t, price),
+		Quantity: quantity,
+	})
+	if err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	return id
+}
+
+func mustAddDescribedItem(t *testing.T, d db.DB, owner, name, desc string) string {
+	t.Helper()
+
+	id, err := d.AddItem(context.Background(), &domain.AddItemRequest{
+		OwnerID:     owner,
+		Name:        name,
+		Description: desc,
+		Category:    "misc",
+		Price:       money(t, "1.00"),
+		Quantity:    1,
+	})
+	if err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	return id
+}
+
+func mustGetItem(t *testing.T, d db.DB, id string) *domain.Item {
+	t.Helper()
+
+	it, err := d.GetItemById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetItemById() error = %v", err)
+	}
+
+	return it
+}
+
+func line(item string, quantity int64) domain.OrderItem {
+	return domain.OrderItem{ID: item, Quantity: quantity}
+}
+
+func mustCreateOrder(t *testing.T, d db.DB, customer string, lines ...domain.OrderItem) string {
+	t.Helper()
+
+	id, err := d.CreateOrder(context.Background(), &domain.CreateOrderRequest{
+		CustomerID: customer,
+		Items:      lines,
+	})
+	if err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	return id
+}
+
+func mustGetCart(t *testing.T, d db.DB, user string) *domain.Cart {
+	t.Helper()
+
+	cart, err := d.GetCart(context.Background(), user)
+	if err != nil {
+		t.Fatalf("GetCart() error = %v", err)
+	}
+
+	return cart
+}
+
+func mustSetCartItem(t *testing.T, d db.DB, user, item string, quantity int64) {
+	t.Helper()
+
+	if err := d.SetCartItem(context.Background(), user, item, quantity, mustGetCart(t, d, user).Version); err != nil {
+		t.Fatalf("SetCartItem() error = %v", err)
+	}
+}
+
+// assertCartItems checks items of the cart and their order, the one
+// they were first added in.
+func assertCartItems(t *testing.T, cart *domain.Cart, want ...domain.OrderItem) {
+	t.Helper()
+
+	got := make([]domain.OrderItem, len(cart.Items))
+	for i, it := range cart.Items {
+		got[i] = line(it.ItemID, it.Quantity)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cart items = %v, want %v", got, want)
+	}
+}
+
+func mustTopUp(t *testing.T, d db.DB, user, amount string) *domain.Transaction {
+	t.Helper()
+
+	tx, err := d.PostTransaction(context.Background(), &domain.PostTransactionRequest{
+		UserID: user,
+		Kind:   domain.TOPUP,
+		Amount: money(t, amount),
+	})
+	if err != nil {
+		t.Fatalf("PostTransaction() error = %v", err)
+	}
+
+	return tx
+}
+
+func assertBalance(t *testing.T, d db.DB, user, want string) {
+	t.Helper()
+
+	u, err := d.GetUserById(context.Background(), user)
+	if err != nil {
+		t.Fatalf("GetUserById() error = %v", err)
+	}
+
+	if u.Balance != money(t, want) {
+		t.Errorf("balance of %s = %v, want %s", user, u.Balance, want)
+	}
+}
+
+func assertItemIDs(t *testing.T, items []*domain.Item, want ...string) {
+	t.Helper()
+
+	got := map[string]bool{}
+	for _, it := range items {
+		got[it.ID] = true
+	}
+
+	if len(items) != len(want) {
+		t.Errorf("got %d items, want %d", len(items), len(want))
+	}
+	for _, id := range want {
+		if !got[id] {
+			t.Errorf("item %s is missing from result", id)
+		}
+	}
+}
+
+// assertSameIDs checks that records listed by call are the wanted ones,
+// each listed once, in any order.
+func assertSameIDs(t *testing.T, call string, got, want []string) {
+	t.Helper()
+
+	got = append([]string(nil), got...)
+	want = append([]string(nil), want...)
+	sort.Strings(got)
+	sort.Strings(want)
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", call, got, want)
+	}
+}
+
+func assertCategoryCounts(t *testing.T, facets *domain.ItemFacets, want ...domain.CategoryCount) {
+	t.Helper()
+
+	if !reflect.DeepEqual(facets.Categories, want) {
+		t.Errorf("category facets = %v, want %v", facets.Categories, want)
+	}
+}
+
+func assertPriceCounts(t *testing.T, facets *domain.ItemFacets, want ...int64) {
+	t.Helper()
+
+	got := make([]int64, 0, len(facets.Prices))
+	for _, p := range facets.Prices {
+		got = append(got, p.Count)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("price facets = %v, want %v", got, want)
+	}
+}
+
+func hitIDs(hits []*domain.SearchHit) []string {
+	ids := make([]string, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.Item.ID)
+	}
+
+	return ids
+}
+
+func userIDs(users []*domain.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,238 @@
+// Package instrumented wraps a db.DB to record latency of every storage
+// operation in Prometheus.
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/metrics"
+)
+
+var _ db.DB = (*DB)(nil)
+
+type DB struct {
+	next   db.DB
+	driver string
+}
+
+// New returns next with every method measured under the driver label.
+func New(next db.DB, driver string) *DB {
+	return &DB{
+		next:   next,
+		driver: driver,
+	}
+}
+
+func (d *DB) observe(method string, start time.Time, err *error) {
+	outcome := metrics.OutcomeOK
+	if *err != nil {
+		outcome = metrics.OutcomeError
+	}
+
+	metrics.DBDuration.WithLabelValues(d.driver, method, outcome).Observe(time.Since(start).Seconds())
+}
+
+// Ping isn't measured, since it's called by readiness probes rather
+// than by requests.
+func (d *DB) Ping(ctx context.Context) error {
+	return d.next.Ping(ctx)
+}
+
+func (d *DB) Close() error {
+	return d.next.Close()
+}
+
+func (d *DB) AddItem(ctx context.Context, item *domain.AddItemRequest) (id string, err error) {
+	defer d.observe("AddItem", time.Now(), &err)
+
+	return d.next.AddItem(ctx, item)
+}
+
+func (d *DB) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (count int64, err error) {
+	defer d.observe("UpdateItem", time.Now(), &err)
+
+	return d.next.UpdateItem(ctx, id, in)
+}
+
+func (d *DB) GetItemById(ctx context.Context, id string) (item *domain.Item, err error) {
+	defer d.observe("GetItemById", time.Now(), &err)
+
+	return d.next.GetItemById(ctx, id)
+}
+
+func (d *DB) FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (items *domain.ItemsPage, err error) {
+	defer d.observe("FindItems", time.Now(), &err)
+
+	return d.next.FindItems(ctx, filter, page)
+}
+
+func (d *DB) CountItemFacets(ctx context.Context, filter domain.ItemFilter, buckets domain.PriceBuckets) (facets *domain.ItemFacets, err error) {
+	defer d.observe("CountItemFacets", time.Now(), &err)
+
+	return d.next.CountItemFacets(ctx, filter, buckets)
+}
+
+func (d *DB) SearchItems(ctx context.Context, req domain.SearchRequest) (hits []*domain.SearchHit, err error) {
+	defer d.observe("SearchItems", time.Now(), &err)
+
+	return d.next.SearchItems(ctx, req)
+}
+
+func (d *DB) RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (id string, err error) {
+	defer d.observe("RegisterUser", time.Now(), &err)
+
+	return d.next.RegisterUser(ctx, user)
+}
+
+func (d *DB) GetUserById(ctx context.Context, id string) (user *domain.User, err error) {
+	defer d.observe("GetUserById", time.Now(), &err)
+
+	return d.next.GetUserById(ctx, id)
+}
+
+func (d *DB) FindUsers(ctx context.Context, page domain.PageRequest) (users *domain.UsersPage, err error) {
+	defer d.observe("FindUsers", time.Now(), &err)
+
+	return d.next.FindUsers(ctx, page)
+}
+
+func (d *DB) GetUserCredentials(ctx context.Context, email string) (creds *domain.Credentials, err error) {
+	defer d.observe("GetUserCredentials", time.Now(), &err)
+
+	return d.next.GetUserCredentials(ctx, email)
+}
+
+func (d *DB) AddUserRole(ctx context.Context, id string, role domain.Role) (err error) {
+	defer d.observe("AddUserRole", time.Now(), &err)
+
+	return d.next.AddUserRole(ctx, id, role)
+}
+
+func (d *DB) RemoveUserRole(ctx context.Context, id string, role domain.Role) (err error) {
+	defer d.observe("RemoveUserRole", time.Now(), &err)
+
+	return d.next.RemoveUserRole(ctx, id, role)
+}
+
+func (d *DB) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (id string, err error) {
+	defer d.observe("CreateOrder", time.Now(), &err)
+
+	return d.next.CreateOrder(ctx, req)
+}
+
+func (d *DB) GetOrderInfo(ctx context.Context, id string) (order *domain.Order, err error) {
+	defer d.observe("GetOrderInfo", time.Now(), &err)
+
+	return d.next.GetOrderInfo(ctx, id)
+}
+
+func (d *DB) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (orders *domain.OrdersPage, err error) {
+	defer d.observe("GetOrdersByCustomerId", time.Now(), &err)
+
+	return d.next.GetOrdersByCustomerId(ctx, id, page)
+}
+
+func (d *DB) GetUnpaidOrderIds(ctx context.Context, createdBefore time.Time, limit int64) (ids []string, err error) {
+	defer d.observe("GetUnpaidOrderIds", time.Now(), &err)
+
+	return d.next.GetUnpaidOrderIds(ctx, createdBefore, limit)
+}
+
+func (d *DB) UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrderRequest) (err error) {
+	defer d.observe("UpdateOrder", time.Now(), &err)
+
+	return d.next.UpdateOrder(ctx, id, ord)
+}
+
+func (d *DB) TransitionOrder(ctx context.Context, id string, tr domain.StatusTransition) (err error) {
+	defer d.observe("TransitionOrder", time.Now(), &err)
+
+	return d.next.TransitionOrder(ctx, id, tr)
+}
+
+func (d *DB) PayOrder(ctx context.Context, id string, tr domain.StatusTransition) (err error) {
+	defer d.observe("PayOrder", time.Now(), &err)
+
+	return d.next.PayOrder(ctx, id, tr)
+}
+
+func (d *DB) RefundOrder(ctx context.Context, id string, tr domain.StatusTransition) (err error) {
+	defer d.observe("RefundOrder", time.Now(), &err)
+
+	return d.next.RefundOrder(ctx, id, tr)
+}
+
+func (d *DB) PostTransaction(ctx context.Context, req *domain.PostTransactionRequest) (tx *domain.Transaction, err error) {
+	defer d.observe("PostTransaction", time.Now(), &err)
+
+	return d.next.PostTransaction(ctx, req)
+}
+
+func (d *DB) Withdraw(ctx context.Context, userID string, amount domain.Money) (tx *domain.Transaction, err error) {
+	defer d.observe("Withdraw", time.Now(), &err)
+
+	return d.next.Withdraw(ctx, userID, amount)
+}
+
+func (d *DB) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (txs *domain.TransactionsPage, err error) {
+	defer d.observe("GetTransactions", time.Now(), &err)
+
+	return d.next.GetTransactions(ctx, userID, page)
+}
+
+func (d *DB) RecomputeBalance(ctx context.Context, userID string) (balance domain.Money, err error) {
+	defer d.observe("RecomputeBalance", time.Now(), &err)
+
+	return d.next.RecomputeBalance(ctx, userID)
+}
+
+func (d *DB) GetCart(ctx context.Context, userID string) (cart *domain.Cart, err error) {
+	defer d.observe("GetCart", time.Now(), &err)
+
+	return d.next.GetCart(ctx, userID)
+}
+
+func (d *DB) SetCartItem(ctx context.Context, userID, itemID string, quantity, version int64) (err error) {
+	defer d.observe("SetCartItem", time.Now(), &err)
+
+	return d.next.SetCartItem(ctx, userID, itemID, quantity, version)
+}
+
+func (d *DB) RemoveCartItem(ctx context.Context, userID, itemID string) (err error) {
+	defer d.observe("RemoveCartItem", time.Now(), &err)
+
+	return d.next.RemoveCartItem(ctx, userID, itemID)
+}
+
+func (d *DB) ClearCart(ctx context.Context, userID string) (err error) {
+	defer d.observe("ClearCart", time.Now(), &err)
+
+	return d.next.ClearCart(ctx, userID)
+}
+
+func (d *DB) ClaimIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (stored *domain.IdempotencyRecord, err error) {
+	defer d.observe("ClaimIdempotencyKey", time.Now(), &err)
+
+	return d.next.ClaimIdempotencyKey(ctx, rec)
+}
+
+func (d *DB) CompleteIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (err error) {
+	defer d.observe("CompleteIdempotencyKey", time.Now(), &err)
+
+	return d.next.CompleteIdempotencyKey(ctx, rec)
+}
+
+func (d *DB) ReleaseIdempotencyKey(ctx context.Context, key string) (err error) {
+	defer d.observe("ReleaseIdempotencyKey", time.Now(), &err)
+
+	return d.next.ReleaseIdempotencyKey(ctx, key)
+}
+
+func (d *DB) PurgeIdempotencyKeys(ctx context.Context) (err error) {
+	defer d.observe("PurgeIdempotencyKeys", time.Now(), &err)
+
+	return d.next.PurgeIdempotencyKeys(ctx)
+}
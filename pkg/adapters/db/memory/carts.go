@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) GetCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	cart, ok := db.carts[userID]
+	if !ok {
+		return &domain.Cart{UserID: userID, Items: []domain.CartItem{}}, nil
+	}
+
+	return copyCart(cart), nil
+}
+
+func (db *DB) SetCartItem(ctx context.Context, userID, itemID string, quantity, version int64) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+	span.SetTag("quantity", quantity)
+	span.SetTag("cart_version", version)
+
+	if !validID(userID) || !validID(itemID) {
+		return domain.ErrInvalidId
+	}
+
+	if quantity <= 0 {
+		return domain.ErrCartQuantity
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	cart := db.cart(userID)
+	if cart.Version != version {
+		return domain.ErrCartChanged
+	}
+
+	found := false
+	for i := range cart.Items {
+		if cart.Items[i].ItemID == itemID {
+			cart.Items[i].Quantity = quantity
+			found = true
+		}
+	}
+
+	if !found {
+		cart.Items = append(cart.Items, domain.CartItem{
+			ItemID:   itemID,
+			Quantity: quantity,
+			AddedAt:  time.Now(),
+		})
+	}
+
+	touchCart(cart)
+
+	return nil
+}
+
+func (db *DB) RemoveCartItem(ctx context.Context, userID, itemID string) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+
+	if !validID(userID) || !validID(itemID) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	cart := db.cart(userID)
+
+	items := make([]domain.CartItem, 0, len(cart.Items))
+	for _, it := range cart.Items {
+		if it.ItemID != itemID {
+			items = append(items, it)
+		}
+	}
+
+	if len(items) == len(cart.Items) {
+		return domain.ErrCartItemNotFound
+	}
+
+	cart.Items = items
+	touchCart(cart)
+
+	return nil
+}
+
+func (db *DB) ClearCart(ctx context.Context, userID string) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !validID(userID) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	clearCart(db.cart(userID))
+
+	return nil
+}
+
+// cart returns the cart of the user, creating an empty one if the user
+// has none. The caller must hold the lock.
+func (db *DB) cart(userID string) *domain.Cart {
+	cart, ok := db.carts[userID]
+	if !ok {
+		cart = &domain.Cart{UserID: userID, Items: []domain.CartItem{}}
+		db.carts[userID] = cart
+	}
+
+	return cart
+}
+
+func clearCart(cart *domain.Cart) {
+	cart.Items = []domain.CartItem{}
+	touchCart(cart)
+}
+
+func touchCart(cart *domain.Cart) {
+	cart.Version++
+	cart.UpdatedAt = time.Now()
+}
+
+func copyCart(cart *domain.Cart) *domain.Cart {
+	cp := *cart
+	cp.Items = append([]domain.CartItem{}, cart.Items...)
+
+	return &cp
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+var _ db.DB = (*DB)(nil)
+
+// DB keeps everything in process memory. It's meant for tests and local
+// development and loses all data on exit.
+type DB struct {
+	mu sync.RWMutex
+
+	items        map[string]*domain.Item
+	users        map[string]*domain.User
+	passwords    map[string][]byte
+	orders       map[string]*domain.Order
+	transactions map[string]*domain.Transaction
+	carts        map[string]*domain.Cart
+	idempotency  map[string]*domain.IdempotencyRecord
+}
+
+func New() *DB {
+	return &DB{
+		items:        make(map[string]*domain.Item),
+		users:        make(map[string]*domain.User),
+		passwords:    make(map[string][]byte),
+		orders:       make(map[string]*domain.Order),
+		transactions: make(map[string]*domain.Transaction),
+		carts:        make(map[string]*domain.Cart),
+		idempotency:  make(map[string]*domain.IdempotencyRecord),
+	}
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (db *DB) Close() error {
+	return nil
+}
+
+// newID returns a random 24 characters hex string, the same shape as IDs
+// of the MongoDB adapter.
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 12
+}
+
+// findItems returns items that match in page order, up to one more than
+// the page limit. The caller must hold the lock.
+func (db *DB) findItems(match func(it *domain.Item) bool, page domain.PageRequest) []*domain.Item {
+	result := make([]*domain.Item, 0)
+	for _, it := range db.items {
+		if match(it) && follows(page, it.SortKey(page.Sort), it.ID) {
+			result = append(result, copyItem(it))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return precedes(page.Sort,
+			result[i].SortKey(page.Sort), result[i].ID,
+			result[j].SortKey(page.Sort), result[j].ID)
+	})
+
+	return truncate(result, page.Limit+1)
+}
+
+// precedes reports whether the record with key a and ID idA goes before
+// the one with key b and ID idB when sorted in the order.
+func precedes(order domain.SortOrder, a int64, idA string, b int64, idB string) bool {
+	if order.Descending() {
+		a, idA, b, idB = b, idB, a, idA
+	}
+
+	return a < b || a == b && idA < idB
+}
+
+// follows reports whether the record with the key and ID goes after the
+// page cursor.
+func follows(page domain.PageRequest, key int64, id string) bool {
+	if page.After == nil {
+		return true
+	}
+
+	return precedes(page.Sort, page.After.Key, page.After.ID, key, id)
+}
+
+func truncate[T any](s []T, n int64) []T {
+	if int64(len(s)) > n {
+		return s[:n]
+	}
+
+	return s
+}
+
+func copyItem(it *domain.Item) *domain.Item {
+	cp := *it
+	return &cp
+}
+
+func copyUser(user *domain.User) *domain.User {
+	cp := *user
+	cp.Roles = append([]domain.Role{}, user.Roles...)
+
+	return &cp
+}
+
+func copyOrder(ord *domain.Order) *domain.Order {
+	cp := *ord
+	cp.Items = append([]domain.OrderItem{}, ord.Items...)
+	cp.Transitions = append([]domain.StatusTransition{}, ord.Transitions...)
+
+	return &cp
+}
+
+func copyTransaction(tx *domain.Transaction) *domain.Transaction {
+	cp := *tx
+	return &cp
+}
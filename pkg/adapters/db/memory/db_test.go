@@ -0,0 +1,18 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/dbtest"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/memory"
+)
+
+func TestDB(t *testing.T) {
+	dbtest.Run(t, dbtest.Adapter{
+		New: func(t *testing.T) db.DB {
+			return memory.New()
+		},
+		UnknownID: "000000000000000000000000",
+	})
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// ClaimIdempotencyKey treats an expired record of the key as missing and
+// replaces it. Other expired records are left to PurgeIdempotencyKeys.
+func (db *DB) ClaimIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if stored, ok := db.idempotency[rec.Key]; ok && stored.ExpiresAt.After(time.Now()) {
+		return copyIdempotencyRecord(stored), nil
+	}
+
+	db.idempotency[rec.Key] = copyIdempotencyRecord(rec)
+
+	return nil, nil
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	if rec.Response == nil {
+		return nil
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	stored, ok := db.idempotency[rec.Key]
+	if ok && stored.Fingerprint == rec.Fingerprint {
+		db.idempotency[rec.Key] = copyIdempotencyRecord(rec)
+	}
+
+	return nil
+}
+
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", key)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.idempotency, key)
+
+	return nil
+}
+
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	for key, stored := range db.idempotency {
+		if !stored.ExpiresAt.After(now) {
+			delete(db.idempotency, key)
+		}
+	}
+
+	return nil
+}
+
+func copyIdempotencyRecord(rec *domain.IdempotencyRecord) *domain.IdempotencyRecord {
+	cp := *rec
+	if rec.Response != nil {
+		resp := *rec.Response
+		resp.Body = append([]byte(nil), rec.Response.Body...)
+		cp.Response = &resp
+	}
+
+	return &cp
+}
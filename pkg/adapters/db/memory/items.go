@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) AddItem(ctx context.Context, item *domain.AddItemRequest) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if item == nil {
+		return "", domain.ErrNoItem
+	}
+
+	if !validID(item.OwnerID) {
+		return "", domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	it := &domain.Item{
+		ID:          newID(),
+		OwnerID:     item.OwnerID,
+		Name:        item.Name,
+		Description: item.Description,
+		Category:    item.Category,
+		Price:       domain.NewMoney(item.Price.Amount, item.Price.Currency),
+		CreatedAt:   time.Now(),
+		Quantity:    item.Quantity,
+	}
+	db.items[it.ID] = it
+
+	span.SetTag("result_id", it.ID)
+
+	return it.ID, nil
+}
+
+func (db *DB) GetItemById(ctx context.Context, id string) (*domain.Item, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("item_id", id)
+
+	if !validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	it, ok := db.items[id]
+	if !ok {
+		return nil, domain.ErrItemNotFound
+	}
+
+	return copyItem(it), nil
+}
+
+func (db *DB) FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if filter.OwnerID != "" && !validID(filter.OwnerID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	if page.After != nil && !validID(page.After.ID) {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	items := db.findItems(func(it *domain.Item) bool {
+		return matchItem(it, &filter)
+	}, page)
+
+	return domain.NewItemsPage(items, page), nil
+}
+
+func (db *DB) SearchItems(ctx context.Context, req domain.SearchRequest) ([]*domain.SearchHit, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("query", req.Query)
+	span.SetTag("limit", req.Limit)
+
+	matcher := domain.NewItemMatcher(req.Query)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	hits := make([]*domain.SearchHit, 0)
+	for _, it := range db.items {
+		if hit, ok := matcher.Match(it); ok {
+			hit.Item = copyItem(it)
+			hits = append(hits, hit)
+		}
+	}
+
+	return domain.RankHits(hits, req.Limit), nil
+}
+
+func (db *DB) CountItemFacets(ctx context.Context, filter domain.ItemFilter, buckets domain.PriceBuckets) (*domain.ItemFacets, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("currency", buckets.Currency)
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if filter.OwnerID != "" && !validID(filter.OwnerID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	var (
+		categoryFilter = filter.CategoryFacetFilter()
+		priceFilter    = filter.PriceFacetFilter(buckets)
+		categories     = make(map[string]int64)
+		prices         = make([]int64, len(buckets.Bounds))
+	)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, it := range db.items {
+		if matchItem(it, &categoryFilter) {
+			categories[it.Category]++
+		}
+
+		if matchItem(it, &priceFilter) {
+			if i := buckets.Bucket(it.Price.Amount); i >= 0 {
+				prices[i]++
+			}
+		}
+	}
+
+	return domain.NewItemFacets(categories, prices, buckets), nil
+}
+
+func matchItem(it *domain.Item, filter *domain.ItemFilter) bool {
+	if filter.OwnerID != "" && it.OwnerID != filter.OwnerID {
+		return false
+	}
+
+	if len(filter.Categories) > 0 && !contains(filter.Categories, it.Category) {
+		return false
+	}
+
+	if filter.InStock && it.Quantity == 0 {
+		return false
+	}
+
+	if currency := filter.PriceCurrency(); currency != "" && it.Price.Currency != currency {
+		return false
+	}
+
+	if filter.MinPrice != nil && it.Price.Amount < filter.MinPrice.Amount {
+		return false
+	}
+
+	if filter.MaxPrice != nil && it.Price.Amount > filter.MaxPrice.Amount {
+		return false
+	}
+
+	return !it.CreatedAt.Before(filter.CreatedAfter)
+}
+
+func (db *DB) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if !validID(id) {
+		return 0, domain.ErrInvalidId
+	}
+
+	if in == nil || *in == (domain.UpdateItemRequest{}) {
+		return 0, domain.ErrNoUpdate
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	it, ok := db.items[id]
+	if !ok {
+		return 0, domain.ErrItemNotFound
+	}
+
+	// Like MongoDB, report the item as modified only if a value changed.
+	updated := *it
+	if in.Name != nil {
+		updated.Name = *in.Name
+	}
+	if in.Description != nil {
+		updated.Description = *in.Description
+	}
+	if in.Category != nil {
+		updated.Category = *in.Category
+	}
+	if in.OwnerID != nil {
+		updated.OwnerID = *in.OwnerID
+	}
+	if in.Price != nil {
+		updated.Price = domain.NewMoney(in.Price.Amount, in.Price.Currency)
+	}
+	if in.Quantity != nil {
+		updated.Quantity = *in.Quantity
+	}
+
+	if updated == *it {
+		return 0, nil
+	}
+
+	*it = updated
+
+	return 1, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
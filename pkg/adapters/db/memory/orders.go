@@ -0,0 +1,407 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if req == nil {
+		return "", domain.ErrNoOrder
+	}
+
+	for _, it := range req.Items {
+		if !validID(it.ID) {
+			return "", domain.ErrInvalidId
+		}
+	}
+
+	if !validID(req.CustomerID) {
+		return "", domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Check the whole order first, so that nothing is reserved if any
+	// line is short.
+	reserved := make(map[string]uint64, len(req.Items))
+	var short []string
+	for _, line := range req.Items {
+		it, ok := db.items[line.ID]
+		if !ok || it.Quantity < reserved[line.ID]+uint64(line.Quantity) {
+			short = append(short, line.ID)
+			continue
+		}
+
+		reserved[line.ID] += uint64(line.Quantity)
+	}
+
+	if len(short) > 0 {
+		span.SetTag("short_items", short)
+		return "", domain.NewInsufficientStockError(short)
+	}
+
+	var cart *domain.Cart
+	if req.CartVersion != 0 {
+		cart = db.carts[req.CustomerID]
+		if cart == nil || cart.Version != req.CartVersion {
+			return "", domain.ErrCartChanged
+		}
+	}
+
+	order := &domain.Order{
+		ID:          newID(),
+		Items:       make([]domain.OrderItem, 0, len(req.Items)),
+		CreatedAt:   time.Now(),
+		Status:      domain.CREATED,
+		CustomerID:  req.CustomerID,
+		Transitions: []domain.StatusTransition{},
+	}
+
+	for _, line := range req.Items {
+		it := db.items[line.ID]
+
+		subtotal, err := it.Price.Mul(line.Quantity)
+		if err != nil {
+			return "", err
+		}
+
+		total, err := order.Total.Add(subtotal)
+		if err != nil {
+			return "", err
+		}
+
+		order.Total = total
+		order.Items = append(order.Items, domain.OrderItem{
+			ID:       line.ID,
+			Quantity: line.Quantity,
+			Price:    it.Price,
+			SellerID: it.OwnerID,
+		})
+	}
+
+	for id, quantity := range reserved {
+		db.items[id].Quantity -= quantity
+	}
+
+	db.orders[order.ID] = order
+
+	if cart != nil {
+		clearCart(cart)
+	}
+
+	span.SetTag("result_id", order.ID)
+
+	return order.ID, nil
+}
+
+func (db *DB) GetOrderInfo(ctx context.Context, id string) (*domain.Order, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if !validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	order, ok := db.orders[id]
+	if !ok {
+		return nil, domain.ErrOrderNotFound
+	}
+
+	return copyOrder(order), nil
+}
+
+func (db *DB) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("customer_id", id)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if !validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	if page.After != nil && !validID(page.After.ID) {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make([]*domain.Order, 0)
+	for _, order := range db.orders {
+		if order.CustomerID == id && follows(page, order.SortKey(page.Sort), order.ID) {
+			result = append(result, copyOrder(order))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return precedes(page.Sort,
+			result[i].SortKey(page.Sort), result[i].ID,
+			result[j].SortKey(page.Sort), result[j].ID)
+	})
+
+	return domain.NewOrdersPage(truncate(result, page.Limit+1), page), nil
+}
+
+func (db *DB) GetUnpaidOrderIds(ctx context.Context, createdBefore time.Time, limit int64) ([]string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("created_before", createdBefore)
+	span.SetTag("limit", limit)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	unpaid := make([]*domain.Order, 0)
+	for _, order := range db.orders {
+		if order.Status == domain.CREATED && order.CreatedAt.Before(createdBefore) {
+			unpaid = append(unpaid, order)
+		}
+	}
+
+	sort.Slice(unpaid, func(i, j int) bool {
+		return unpaid[i].CreatedAt.Before(unpaid[j].CreatedAt)
+	})
+
+	if int64(len(unpaid)) > limit {
+		unpaid = unpaid[:limit]
+	}
+
+	ids := make([]string, len(unpaid))
+	for i, order := range unpaid {
+		ids[i] = order.ID
+	}
+
+	return ids, nil
+}
+
+func (db *DB) UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrderRequest) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if ord.Items != nil {
+		for _, it := range *ord.Items {
+			if !validID(it.ID) {
+				return domain.ErrInvalidId
+			}
+		}
+	}
+
+	if ord.Items == nil && ord.Status == nil {
+		return domain.ErrNoUpdate
+	}
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	order, ok := db.orders[id]
+	if !ok {
+		return domain.ErrOrderNotFound
+	}
+
+	if ord.Items != nil {
+		order.Items = append([]domain.OrderItem{}, *ord.Items...)
+	}
+
+	if ord.Status != nil {
+		order.Status = *ord.Status
+	}
+
+	return nil
+}
+
+func (db *DB) TransitionOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+	span.SetTag("from", string(tr.From))
+	span.SetTag("to", string(tr.To))
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	order, err := db.lockedOrder(id, tr)
+	if err != nil {
+		return err
+	}
+
+	if tr.ReleasesStock() {
+		for _, line := range order.Items {
+			if it, ok := db.items[line.ID]; ok {
+				it.Quantity += uint64(line.Quantity)
+			}
+		}
+	}
+
+	order.Status = tr.To
+	order.Transitions = append(order.Transitions, tr)
+
+	return nil
+}
+
+func (db *DB) PayOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	order, err := db.lockedOrder(id, tr)
+	if err != nil {
+		return err
+	}
+
+	customer, ok := db.users[order.CustomerID]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+
+	short, err := customer.Balance.Less(order.Total)
+	if err != nil {
+		return err
+	}
+
+	if short {
+		return domain.ErrInsufficientFunds
+	}
+
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	entries := []*domain.Transaction{newOrderTransaction(order.CustomerID, order.ID, domain.PAYMENT, order.Total.Neg())}
+	for sellerID, amount := range shares {
+		entries = append(entries, newOrderTransaction(sellerID, order.ID, domain.PAYOUT, amount))
+	}
+
+	if err := db.postTransactions(entries); err != nil {
+		return err
+	}
+
+	order.Status = tr.To
+	order.Transitions = append(order.Transitions, tr)
+
+	return nil
+}
+
+func (db *DB) RefundOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	order, err := db.lockedOrder(id, tr)
+	if err != nil {
+		return err
+	}
+
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	entries := []*domain.Transaction{newOrderTransaction(order.CustomerID, order.ID, domain.REFUND, order.Total)}
+	for sellerID, amount := range shares {
+		entries = append(entries, newOrderTransaction(sellerID, order.ID, domain.REFUND, amount.Neg()))
+	}
+
+	if err := db.postTransactions(entries); err != nil {
+		return err
+	}
+
+	order.Status = tr.To
+	order.Transitions = append(order.Transitions, tr)
+
+	return nil
+}
+
+// lockedOrder returns the order if it's still in tr.From. The caller must
+// hold the write lock.
+func (db *DB) lockedOrder(id string, tr domain.StatusTransition) (*domain.Order, error) {
+	order, ok := db.orders[id]
+	if !ok {
+		return nil, domain.ErrOrderNotFound
+	}
+
+	if order.Status != tr.From {
+		return nil, domain.ErrOrderStatusConflict
+	}
+
+	return order, nil
+}
+
+func sellerShares(items []domain.OrderItem) (map[string]domain.Money, error) {
+	shares := map[string]domain.Money{}
+	for _, item := range items {
+		if item.SellerID == "" {
+			continue
+		}
+
+		share, err := item.Price.Mul(item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		if prev, ok := shares[item.SellerID]; ok {
+			// Lines were priced in one currency when the order was placed.
+			share, err = prev.Add(share)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		shares[item.SellerID] = share
+	}
+
+	return shares, nil
+}
+
+func newOrderTransaction(userID, orderID string, kind domain.TransactionKind, amount domain.Money) *domain.Transaction {
+	return &domain.Transaction{
+		ID:        newID(),
+		UserID:    userID,
+		OrderID:   orderID,
+		Kind:      kind,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+}
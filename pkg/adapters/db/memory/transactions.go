@@ -0,0 +1,194 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) PostTransaction(ctx context.Context, req *domain.PostTransactionRequest) (*domain.Transaction, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", req.UserID)
+	span.SetTag("kind", string(req.Kind))
+	span.SetTag("amount", req.Amount.String())
+
+	if !validID(req.UserID) || (req.OrderID != "" && !validID(req.OrderID)) {
+		return nil, domain.ErrInvalidId
+	}
+
+	tx := &domain.Transaction{
+		ID:        newID(),
+		UserID:    req.UserID,
+		OrderID:   req.OrderID,
+		Kind:      req.Kind,
+		Amount:    domain.NewMoney(req.Amount.Amount, req.Amount.Currency),
+		CreatedAt: time.Now(),
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.postTransactions([]*domain.Transaction{tx}); err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", tx.ID)
+
+	return copyTransaction(tx), nil
+}
+
+func (db *DB) Withdraw(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("amount", amount.String())
+
+	if !validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	tx := &domain.Transaction{
+		ID:        newID(),
+		UserID:    userID,
+		Kind:      domain.WITHDRAWAL,
+		Amount:    amount.Neg(),
+		CreatedAt: time.Now(),
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	short, err := user.Balance.Less(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if short {
+		return nil, domain.ErrInsufficientFunds
+	}
+
+	if err := db.postTransactions([]*domain.Transaction{tx}); err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", tx.ID)
+
+	return copyTransaction(tx), nil
+}
+
+func (db *DB) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if !validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	if page.After != nil && !validID(page.After.ID) {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make([]*domain.Transaction, 0)
+	for _, tx := range db.transactions {
+		if tx.UserID == userID && follows(page, tx.SortKey(page.Sort), tx.ID) {
+			result = append(result, copyTransaction(tx))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return precedes(page.Sort,
+			result[i].SortKey(page.Sort), result[i].ID,
+			result[j].SortKey(page.Sort), result[j].ID)
+	})
+
+	return domain.NewTransactionsPage(truncate(result, page.Limit+1), page), nil
+}
+
+func (db *DB) RecomputeBalance(ctx context.Context, userID string) (domain.Money, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !validID(userID) {
+		return domain.Money{}, domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, ok := db.users[userID]
+	if !ok {
+		return domain.Money{}, domain.ErrUserNotFound
+	}
+
+	balance := domain.NewMoney(0, user.Balance.Currency)
+	for _, tx := range db.transactions {
+		if tx.UserID == userID {
+			balance.Amount += tx.Amount.Amount
+		}
+	}
+
+	user.Balance = balance
+
+	span.SetTag("balance", balance.String())
+
+	return balance, nil
+}
+
+// postTransactions applies all entries or none of them. The caller must
+// hold the write lock.
+func (db *DB) postTransactions(entries []*domain.Transaction) error {
+	balances := make(map[string]domain.Money, len(entries))
+	for _, tx := range entries {
+		user, ok := db.users[tx.UserID]
+		if !ok {
+			return domain.ErrUserNotFound
+		}
+
+		if user.Balance.Currency != tx.Amount.Currency {
+			return domain.ErrCurrencyMismatch
+		}
+
+		balance, ok := balances[tx.UserID]
+		if !ok {
+			balance = user.Balance
+		}
+
+		balance, err := balance.Add(tx.Amount)
+		if err != nil {
+			return err
+		}
+
+		balances[tx.UserID] = balance
+	}
+
+	for _, tx := range entries {
+		db.transactions[tx.ID] = tx
+	}
+
+	for userID, balance := range balances {
+		db.users[userID].Balance = balance
+	}
+
+	return nil
+}
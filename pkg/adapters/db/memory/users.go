@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, u := range db.users {
+		if u.Email == user.Email {
+			return "", domain.ErrEmailTaken
+		}
+	}
+
+	u := &domain.User{
+		ID:        newID(),
+		Name:      user.Name,
+		Email:     user.Email,
+		Phone:     user.Phone,
+		CreatedAt: time.Now(),
+		Balance:   domain.NewMoney(0, domain.DefaultCurrency),
+		Roles:     []domain.Role{},
+	}
+	db.users[u.ID] = u
+	db.passwords[u.ID] = append([]byte(nil), user.PasswordHash...)
+
+	span.SetTag("result_id", u.ID)
+
+	return u.ID, nil
+}
+
+func (db *DB) GetUserById(ctx context.Context, id string) (*domain.User, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+
+	if !validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	u, ok := db.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return copyUser(u), nil
+}
+
+func (db *DB) FindUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if page.After != nil && !validID(page.After.ID) {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	result := make([]*domain.User, 0)
+	for _, u := range db.users {
+		if follows(page, u.SortKey(page.Sort), u.ID) {
+			result = append(result, copyUser(u))
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return precedes(page.Sort,
+			result[i].SortKey(page.Sort), result[i].ID,
+			result[j].SortKey(page.Sort), result[j].ID)
+	})
+
+	return domain.NewUsersPage(truncate(result, page.Limit+1), page), nil
+}
+
+func (db *DB) GetUserCredentials(ctx context.Context, email string) (*domain.Credentials, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("email", email)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, u := range db.users {
+		if u.Email == email {
+			return &domain.Credentials{
+				UserID:       u.ID,
+				PasswordHash: append([]byte(nil), db.passwords[u.ID]...),
+			}, nil
+		}
+	}
+
+	return nil, domain.ErrUserNotFound
+}
+
+func (db *DB) AddUserRole(ctx context.Context, id string, role domain.Role) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	u, ok := db.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+
+	if !hasRole(u.Roles, role) {
+		u.Roles = append(u.Roles, role)
+		domain.SortRoles(u.Roles)
+	}
+
+	return nil
+}
+
+func (db *DB) RemoveUserRole(ctx context.Context, id string, role domain.Role) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
+
+	if !validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	u, ok := db.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+
+	roles := make([]domain.Role, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	u.Roles = roles
+
+	return nil
+}
+
+// hasRole tells if the role is listed, unlike User.HasRole it doesn't
+// treat admins specially.
+func hasRole(roles []domain.Role, role domain.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,181 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) GetCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	obj, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	var result models.Cart
+	if err := db.collectionCarts.FindOne(ctx, bson.M{"_id": obj}).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &domain.Cart{UserID: userID, Items: []domain.CartItem{}}, nil
+		}
+
+		return nil, err
+	}
+
+	return result.ConvertToDomain(), nil
+}
+
+func (db *DB) SetCartItem(ctx context.Context, userID, itemID string, quantity, version int64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+	span.SetTag("quantity", quantity)
+	span.SetTag("cart_version", version)
+
+	userObj, itemObj, err := cartIDs(userID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if quantity <= 0 {
+		return domain.ErrCartQuantity
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	field := "items." + itemObj.Hex()
+	now := time.Now()
+
+	// The pipeline keeps added_at of items already in the cart.
+	update := mongo.Pipeline{
+		{primitive.E{Key: "$set", Value: bson.M{
+			field + ".quantity": quantity,
+			field + ".added_at": bson.M{"$ifNull": bson.A{"$" + field + ".added_at", now}},
+			"version":           bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+			"updated_at":        now,
+		}}},
+	}
+
+	// A cart of another version doesn't match, so the upsert tries to
+	// insert a second cart of the user and fails on its ID.
+	_, err = db.collectionCarts.UpdateOne(ctx, bson.M{"_id": userObj, "version": version}, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrCartChanged
+	}
+
+	return err
+}
+
+func (db *DB) RemoveCartItem(ctx context.Context, userID, itemID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+
+	userObj, itemObj, err := cartIDs(userID, itemID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	field := "items." + itemObj.Hex()
+
+	res, err := db.collectionCarts.UpdateOne(
+		ctx,
+		bson.M{"_id": userObj, field: bson.M{"$exists": true}},
+		bson.M{
+			"$unset": bson.M{field: ""},
+			"$inc":   bson.M{"version": 1},
+			"$set":   bson.M{"updated_at": time.Now()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return domain.ErrCartItemNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) ClearCart(ctx context.Context, userID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	obj, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	_, err = db.collectionCarts.UpdateOne(ctx, bson.M{"_id": obj}, clearCartUpdate(), options.Update().SetUpsert(true))
+
+	return err
+}
+
+// checkoutCart clears the cart if it's still of the version the order
+// was built from.
+func (db *DB) checkoutCart(ctx context.Context, userID primitive.ObjectID, version int64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	res, err := db.collectionCarts.UpdateOne(ctx, bson.M{"_id": userID, "version": version}, clearCartUpdate())
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return domain.ErrCartChanged
+	}
+
+	return nil
+}
+
+func clearCartUpdate() bson.M {
+	return bson.M{
+		"$set": bson.M{"items": bson.M{}, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+}
+
+func cartIDs(userID, itemID string) (primitive.ObjectID, primitive.ObjectID, error) {
+	userObj, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, domain.ErrInvalidId
+	}
+
+	itemObj, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, domain.ErrInvalidId
+	}
+
+	return userObj, itemObj, nil
+}
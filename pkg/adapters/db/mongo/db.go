@@ -2,8 +2,15 @@ package mongo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/Pavel7004/Common/tracing"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -16,63 +23,207 @@ import (
 
 var _ db.DB = (*DB)(nil)
 
+// Backoff of the initial connection attempts.
+const (
+	connectRetryMin = 500 * time.Millisecond
+	connectRetryMax = 30 * time.Second
+)
+
+var ErrNotConnected = errors.New("mongo: initial connection isn't established yet")
+
 type DB struct {
-	client *mongo.Client
-	cfg    *config.MongoCfg
+	client   *mongo.Client
+	cfg      *config.MongoCfg
+	database *mongo.Database
 
 	collectionItems  *mongo.Collection
 	collectionUsers  *mongo.Collection
 	collectionOrders *mongo.Collection
-}
 
-func New(cfg *config.Config) *DB {
-	db := new(DB)
+	collectionTransactions *mongo.Collection
+	collectionCarts        *mongo.Collection
+	collectionIdempotency  *mongo.Collection
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Mongo.Timeout)
-	defer cancel()
+	// ready is set once the server answered a ping and startup
+	// migrations are applied.
+	ready   atomic.Bool
+	lastErr atomic.Value
+	failed  chan error
+	stop    context.CancelFunc
+}
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.Mongo.Uri))
-	if err != nil {
-		panic(err)
-	}
+// New creates the client and connects to MongoDB in the background,
+// retrying with backoff until the server answers. Ping reports
+// ErrNotConnected until then.
+func New(cfg *config.Config) (*DB, error) {
+	db := &DB{failed: make(chan error, 1)}
 
-	if err := client.Ping(context.Background(), readpref.Primary()); err != nil {
-		panic(err)
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(cfg.Mongo.Uri))
+	if err != nil {
+		return nil, err
 	}
 
 	db.client = client
 	db.cfg = &cfg.Mongo
-	db.collectionItems = client.Database("shop").Collection("items")
-	db.collectionUsers = client.Database("shop").Collection("users")
-	db.collectionOrders = client.Database("shop").Collection("orders")
+	db.database = client.Database(cfg.Mongo.Database)
+	db.collectionItems = db.database.Collection("items")
+	db.collectionUsers = db.database.Collection("users")
+	db.collectionOrders = db.database.Collection("orders")
+	db.collectionTransactions = db.database.Collection("transactions")
+	db.collectionCarts = db.database.Collection("carts")
+	db.collectionIdempotency = db.database.Collection("idempotency_keys")
+
+	ctx, stop := context.WithCancel(context.Background())
+	db.stop = stop
+
+	go db.connect(ctx)
+
+	return db, nil
+}
+
+// Failed receives the error of startup migrations that retrying can't
+// fix, like data violating a new unique index. The adapter never becomes
+// ready then.
+func (db *DB) Failed() <-chan error {
+	return db.failed
+}
+
+// connect waits for the server to answer and applies migrations if
+// configured. Migrations are retried only if the connection broke.
+func (db *DB) connect(ctx context.Context) {
+	delay := connectRetryMin
+
+	for {
+		err := db.ping(ctx)
+		if err == nil && db.cfg.MigrateOnStart {
+			err = db.MigrateUp(ctx)
+			if err != nil && !mongo.IsNetworkError(err) && !mongo.IsTimeout(err) {
+				db.lastErr.Store(err)
+				db.failed <- err
+				return
+			}
+		}
+
+		if err == nil {
+			db.ready.Store(true)
+			log.Info().Msg("Connected to mongo")
+			return
+		}
+
+		db.lastErr.Store(err)
+		log.Warn().Err(err).Dur("retry_in", delay).Msg("Mongo isn't available")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > connectRetryMax {
+			delay = connectRetryMax
+		}
+	}
+}
+
+func (db *DB) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	return db.client.Ping(ctx, readpref.Primary())
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if !db.ready.Load() {
+		if err, ok := db.lastErr.Load().(error); ok {
+			return fmt.Errorf("%w: %v", ErrNotConnected, err)
+		}
+
+		return ErrNotConnected
+	}
 
-	return db
+	return db.ping(ctx)
 }
 
 func (db *DB) Close() error {
+	db.stop()
+
 	ctx, cancel := context.WithTimeout(context.Background(), db.cfg.Timeout)
 	defer cancel()
 
 	return db.client.Disconnect(ctx)
 }
 
-func (db *DB) findItems(ctx context.Context, filter interface{}) ([]*domain.Item, error) {
+// findItems returns items matching the filter in page order, up to one
+// more than the page limit.
+func (db *DB) findItems(ctx context.Context, filter bson.M, page domain.PageRequest) ([]*domain.Item, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
+	field := "created_at"
+	if page.Sort.ByPrice() {
+		field = "price.amount"
+	}
+
+	filter, options, err := pageQuery(filter, field, page)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
 	defer cancel()
 
-	cur, err := db.collectionItems.Find(ctx, filter)
+	cur, err := db.collectionItems.Find(ctx, filter, options)
 	if err != nil {
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	var results []models.Item
-	if err := cur.All(ctx, &results); err != nil {
-		return nil, err
+	results := make([]*domain.Item, 0, page.Limit+1)
+	for cur.Next(ctx) {
+		var it models.Item
+		if err := cur.Decode(&it); err != nil {
+			return nil, err
+		}
+
+		results = append(results, it.ConvertToDomain())
 	}
 
-	return models.ConvertItemsToDomain(results), nil
+	return results, cur.Err()
+}
+
+// pageQuery restricts the filter to documents following the page cursor
+// and returns options sorting them by field and then by ID. One document
+// more than the page limit is fetched to tell if there is a next page.
+func pageQuery(filter bson.M, field string, page domain.PageRequest) (bson.M, *options.FindOptions, error) {
+	dir, op := 1, "$gt"
+	if page.Sort.Descending() {
+		dir, op = -1, "$lt"
+	}
+
+	if page.After != nil {
+		id, err := primitive.ObjectIDFromHex(page.After.ID)
+		if err != nil {
+			return nil, nil, domain.ErrInvalidCursor
+		}
+
+		var key interface{} = page.After.Key
+		if field == "created_at" {
+			key = time.Unix(0, page.After.Key)
+		}
+
+		filter = bson.M{"$and": bson.A{filter, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{op: key}},
+			bson.M{field: key, "_id": bson.M{op: id}},
+		}}}}
+	}
+
+	options := options.Find()
+	options.SetSort(bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}})
+	options.SetLimit(page.Limit + 1)
+
+	return filter, options, nil
 }
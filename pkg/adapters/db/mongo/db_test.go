@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/dbtest"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+// TestDB needs a local mongod started as a replica set, since orders and
+// payments use multi-document transactions:
+//
+//	mongod --replSet rs0 && mongosh --eval 'rs.initiate()'
+//	MONGO_TEST_URI=mongodb://localhost:27017/?replicaSet=rs0 go test ./...
+func TestDB(t *testing.T) {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI isn't set")
+	}
+
+	dbtest.Run(t, dbtest.Adapter{
+		New: func(t *testing.T) db.DB {
+			d, err := New(&config.Config{
+				Mongo: config.MongoCfg{
+					Uri:      uri,
+					Database: fmt.Sprintf("shop_test_%d", time.Now().UnixNano()),
+					Timeout:  10 * time.Second,
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+
+			t.Cleanup(func() {
+				if err := d.collectionItems.Database().Drop(context.Background()); err != nil {
+					t.Errorf("failed to drop test database: %v", err)
+				}
+				if err := d.Close(); err != nil {
+					t.Errorf("failed to close connection: %v", err)
+				}
+			})
+
+			if err := d.MigrateUp(context.Background()); err != nil {
+				t.Fatalf("failed to apply migrations: %v", err)
+			}
+
+			return d
+		},
+		UnknownID: "000000000000000000000000",
+	})
+}
+
+// newMigrationDB returns a connection to an empty database without
+// migrations applied, so tests can put old data in before migrating.
+func newMigrationDB(t *testing.T) *DB {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI isn't set")
+	}
+
+	d, err := New(&config.Config{
+		Mongo: config.MongoCfg{
+			Uri:      uri,
+			Database: fmt.Sprintf("shop_test_%d", time.Now().UnixNano()),
+			Timeout:  10 * time.Second,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := d.database.Drop(context.Background()); err != nil {
+			t.Errorf("failed to drop test database: %v", err)
+		}
+		if err := d.Close(); err != nil {
+			t.Errorf("failed to close connection: %v", err)
+		}
+	})
+
+	return d
+}
+
+func TestMigrateDuplicateEmails(t *testing.T) {
+	ctx := context.Background()
+	d := newMigrationDB(t)
+
+	_, err := d.collectionUsers.InsertMany(ctx, []interface{}{
+		bson.M{"email": "twice@example.com"},
+		bson.M{"email": "twice@example.com"},
+		bson.M{"email": "once@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert users: %v", err)
+	}
+
+	err = d.MigrateUp(ctx)
+	if !errors.Is(err, ErrDuplicateEmails) {
+		t.Fatalf("MigrateUp() error = %v, want %v", err, ErrDuplicateEmails)
+	}
+
+	if msg := err.Error(); !strings.Contains(msg, "twice@example.com") || strings.Contains(msg, "once@example.com") {
+		t.Errorf("MigrateUp() error = %q, want only twice@example.com listed", msg)
+	}
+}
+
+func TestMigrateStringOwnerIDs(t *testing.T) {
+	ctx := context.Background()
+	d := newMigrationDB(t)
+
+	owner := primitive.NewObjectID()
+	valid, empty, junk := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	_, err := d.collectionItems.InsertMany(ctx, []interface{}{
+		bson.M{"_id": valid, "owner_id": owner.Hex()},
+		bson.M{"_id": empty, "owner_id": ""},
+		bson.M{"_id": junk, "owner_id": "not-an-object-id"},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert items: %v", err)
+	}
+
+	if err := d.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	want := map[primitive.ObjectID]interface{}{
+		valid: owner,
+		empty: "",
+		junk:  "not-an-object-id",
+	}
+	for id, w := range want {
+		var item bson.M
+		if err := d.collectionItems.FindOne(ctx, bson.M{"_id": id}).Decode(&item); err != nil {
+			t.Fatalf("failed to find item %s: %v", id.Hex(), err)
+		}
+
+		if item["owner_id"] != w {
+			t.Errorf("owner of item %s = %#v, want %#v", id.Hex(), item["owner_id"], w)
+		}
+	}
+}
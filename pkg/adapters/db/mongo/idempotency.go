@@ -0,0 +1,94 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// ClaimIdempotencyKey deletes an expired record of the key first: the
+// TTL index removes expired records only once a minute.
+func (db *DB) ClaimIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	_, err := db.collectionIdempotency.DeleteOne(ctx, bson.M{"_id": rec.Key, "expires_at": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+
+	claim := models.ConvertIdempotencyRecordFromDomain(rec)
+	claim.Response = nil
+
+	_, err = db.collectionIdempotency.InsertOne(ctx, claim)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var stored models.IdempotencyRecord
+	if err := db.collectionIdempotency.FindOne(ctx, bson.M{"_id": rec.Key}).Decode(&stored); err != nil {
+		return nil, err
+	}
+
+	return stored.ConvertToDomain(), nil
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	if rec.Response == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	doc := models.ConvertIdempotencyRecordFromDomain(rec)
+
+	_, err := db.collectionIdempotency.UpdateOne(ctx,
+		bson.M{"_id": rec.Key, "fingerprint": rec.Fingerprint},
+		bson.M{"$set": bson.M{"response": doc.Response, "expires_at": doc.ExpiresAt}},
+	)
+
+	return err
+}
+
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", key)
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	_, err := db.collectionIdempotency.DeleteOne(ctx, bson.M{"_id": key})
+
+	return err
+}
+
+// PurgeIdempotencyKeys does nothing: the TTL index of the collection
+// deletes expired records.
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context) error {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	return nil
+}
@@ -3,12 +3,13 @@ package mongo
 import (
 	"context"
 	"errors"
-	"time"
+	"math"
 
 	"github.com/Pavel7004/Common/tracing"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
 	"github.com/Pavel7004/WebShop/pkg/domain"
@@ -68,32 +69,153 @@ func (db *DB) GetItemById(ctx context.Context, id string) (*domain.Item, error)
 	return result.ConvertToDomain(), nil
 }
 
-func (db *DB) GetItemsByPrice(ctx context.Context, from, to float64) ([]*domain.Item, error) {
+func (db *DB) FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("from", from)
-	span.SetTag("to", to)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
 
-	return db.findItems(ctx, bson.M{"price": bson.M{"$gte": from, "$lte": to}})
+	query, err := models.ConvertItemFilterToBSON(&filter)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := db.findItems(ctx, query, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewItemsPage(items, page), nil
 }
 
-func (db *DB) GetRecentlyAddedItems(ctx context.Context, period time.Duration) ([]*domain.Item, error) {
+func (db *DB) CountItemFacets(ctx context.Context, filter domain.ItemFilter, buckets domain.PriceBuckets) (*domain.ItemFacets, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("period", period.String())
+	span.SetTag("currency", buckets.Currency)
 
-	timeBound := time.Now().Add(-period)
+	categoryFilter := filter.CategoryFacetFilter()
+	categoryQuery, err := models.ConvertItemFilterToBSON(&categoryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	priceFilter := filter.PriceFacetFilter(buckets)
+	priceQuery, err := models.ConvertItemFilterToBSON(&priceFilter)
+	if err != nil {
+		return nil, err
+	}
 
-	return db.findItems(ctx, bson.M{"created_at": bson.M{"$gte": timeBound}})
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	var categories []models.CategoryCount
+	err = db.aggregateItems(ctx, &categories, bson.A{
+		bson.M{"$match": categoryQuery},
+		bson.M{"$group": bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	boundaries := bson.A{}
+	for _, bound := range buckets.Bounds {
+		boundaries = append(boundaries, bound)
+	}
+	boundaries = append(boundaries, int64(math.MaxInt64))
+
+	var prices []models.PriceBucketCount
+	err = db.aggregateItems(ctx, &prices, bson.A{
+		bson.M{"$match": priceQuery},
+		bson.M{"$bucket": bson.M{
+			"groupBy":    "$price.amount",
+			"boundaries": boundaries,
+			"default":    "other",
+			"output":     bson.M{"count": bson.M{"$sum": 1}},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	categoryCounts := make(map[string]int64, len(categories))
+	for _, c := range categories {
+		categoryCounts[c.Category] = c.Count
+	}
+
+	priceCounts := make([]int64, len(buckets.Bounds))
+	for _, p := range prices {
+		if bound, ok := p.Bound.(int64); ok {
+			if i := buckets.Bucket(bound); i >= 0 {
+				priceCounts[i] = p.Count
+			}
+		}
+	}
+
+	return domain.NewItemFacets(categoryCounts, priceCounts, buckets), nil
+}
+
+func (db *DB) aggregateItems(ctx context.Context, result interface{}, pipeline bson.A) error {
+	cur, err := db.collectionItems.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	return cur.All(ctx, result)
+}
+
+// SearchItems uses the text index of items. Mongo scores the results,
+// highlights are made in process.
+func (db *DB) SearchItems(ctx context.Context, req domain.SearchRequest) ([]*domain.SearchHit, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("query", req.Query)
+	span.SetTag("limit", req.Limit)
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+
+	options := options.Find()
+	options.SetProjection(bson.M{"score": score})
+	options.SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}})
+	options.SetLimit(req.Limit)
+
+	cur, err := db.collectionItems.Find(ctx, bson.M{"$text": bson.M{"$search": req.Query}}, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	matcher := domain.NewItemMatcher(req.Query)
+
+	hits := make([]*domain.SearchHit, 0, req.Limit)
+	for cur.Next(ctx) {
+		var it models.ScoredItem
+		if err := cur.Decode(&it); err != nil {
+			return nil, err
+		}
+
+		item := it.ConvertToDomain()
+		hits = append(hits, &domain.SearchHit{
+			Item:       item,
+			Score:      it.Score,
+			Highlights: matcher.Highlight(item),
+		})
+	}
+
+	return hits, cur.Err()
 }
 
 func (db *DB) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	userID, err := primitive.ObjectIDFromHex(id)
+	itemID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return 0, domain.ErrInvalidId
 	}
@@ -103,14 +225,17 @@ func (db *DB) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRe
 		return 0, err
 	}
 
-	res, err := db.collectionItems.UpdateOne(ctx, bson.M{"_id": userID}, req)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return 0, domain.ErrItemNotFound
-		}
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
 
+	res, err := db.collectionItems.UpdateOne(ctx, bson.M{"_id": itemID}, req)
+	if err != nil {
 		return 0, err
 	}
 
+	if res.MatchedCount == 0 {
+		return 0, domain.ErrItemNotFound
+	}
+
 	return res.ModifiedCount, nil
 }
@@ -0,0 +1,336 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionMigrations = "schema_migrations"
+
+var ErrNoMigrations = errors.New("no applied migrations")
+
+var ErrDuplicateEmails = errors.New("users share emails, change them so the unique email index can be created")
+
+// duplicateEmailsReported limits how many shared emails the error of the
+// users_email migration lists.
+const duplicateEmailsReported = 20
+
+// invalidOwnersReported limits how many items with owner IDs that can't
+// be converted the items_owner_id_object_id migration logs.
+const invalidOwnersReported = 20
+
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, db *mongo.Database) error
+	down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// migrations must be sorted by version. Applied migrations must never
+// change; add a new one instead.
+var migrations = []migration{
+	indexMigration(1, "items_price", "items", bson.D{{Key: "price.currency", Value: 1}, {Key: "price.amount", Value: 1}}, false),
+	indexMigration(2, "items_created_at", "items", bson.D{{Key: "created_at", Value: -1}}, false),
+	indexMigration(3, "items_owner_id", "items", bson.D{{Key: "owner_id", Value: 1}}, false),
+	uniqueEmailMigration(4, "users_email"),
+	indexMigration(5, "orders_customer_id", "orders", bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}, false),
+	indexMigration(6, "transactions_user_id", "transactions", bson.D{
+		{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1},
+	}, false),
+	indexMigration(7, "users_created_at", "users", bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, false),
+	indexMigration(8, "items_price_amount", "items", bson.D{{Key: "price.amount", Value: 1}, {Key: "_id", Value: 1}}, false),
+	textIndexMigration(9, "items_text", "items", bson.D{
+		{Key: "name", Value: 10}, {Key: "category", Value: 5}, {Key: "desc", Value: 1},
+	}),
+	{
+		version: 10,
+		name:    "items_owner_id_object_id",
+		up:      convertStringOwnerIDs,
+		down:    func(context.Context, *mongo.Database) error { return nil },
+	},
+	// Mongo deletes expired idempotency records by itself.
+	modelMigration(11, "idempotency_keys_expires_at", "idempotency_keys", mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}),
+	indexMigration(12, "orders_status_created_at", "orders", bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}, false),
+	indexMigration(13, "orders_customer_id_created_at_id", "orders", bson.D{
+		{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1},
+	}, false),
+}
+
+// convertStringOwnerIDs fixes items whose owner was changed by updates
+// that stored the owner ID as a string, so lookups by owner missed them.
+// Updates didn't validate the ID either, so strings that aren't object
+// IDs are left as they are and logged for an operator to clean up.
+// There is nothing to undo: strings were never meant to be stored.
+func convertStringOwnerIDs(ctx context.Context, db *mongo.Database) error {
+	items := db.Collection("items")
+
+	_, err := items.UpdateMany(ctx,
+		bson.M{"owner_id": bson.M{"$type": "string", "$regex": "^[0-9a-fA-F]{24}$"}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"owner_id": bson.M{"$toObjectId": "$owner_id"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	invalid := bson.M{"owner_id": bson.M{"$type": "string"}}
+
+	count, err := items.CountDocuments(ctx, invalid)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	cursor, err := items.Find(ctx, invalid, options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.M{"_id": 1}).
+		SetLimit(invalidOwnersReported))
+	if err != nil {
+		return err
+	}
+
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &found); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(found))
+	for _, it := range found {
+		ids = append(ids, it.ID.Hex())
+	}
+
+	log.Warn().Int64("count", count).Strs("item_ids", ids).
+		Msg("Items have owner IDs that aren't object IDs, set their owners or delete them")
+
+	return nil
+}
+
+// uniqueEmailMigration creates the unique index of user emails. Users
+// registered before the index existed may share an email, then the index
+// can't be built and the migration fails listing the emails instead.
+func uniqueEmailMigration(version int, name string) migration {
+	m := indexMigration(version, name, "users", bson.D{{Key: "email", Value: 1}}, true)
+
+	create := m.up
+	m.up = func(ctx context.Context, db *mongo.Database) error {
+		emails, err := duplicateEmails(ctx, db.Collection("users"))
+		if err != nil {
+			return err
+		}
+
+		if len(emails) > 0 {
+			return fmt.Errorf("%w: %s", ErrDuplicateEmails, strings.Join(emails, ", "))
+		}
+
+		return create(ctx, db)
+	}
+
+	return m
+}
+
+func duplicateEmails(ctx context.Context, users *mongo.Collection) ([]string, error) {
+	cursor, err := users.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$limit", Value: duplicateEmailsReported}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []struct {
+		Email string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, 0, len(groups))
+	for _, g := range groups {
+		emails = append(emails, g.Email)
+	}
+
+	return emails, nil
+}
+
+// indexMigration creates the index on up and drops it on down. The
+// index is named after the migration.
+func indexMigration(version int, name, collection string, keys bson.D, unique bool) migration {
+	return modelMigration(version, name, collection, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetUnique(unique),
+	})
+}
+
+// textIndexMigration creates a text index over the fields with their
+// weights.
+func textIndexMigration(version int, name, collection string, weights bson.D) migration {
+	keys := make(bson.D, 0, len(weights))
+	for _, w := range weights {
+		keys = append(keys, bson.E{Key: w.Key, Value: "text"})
+	}
+
+	return modelMigration(version, name, collection, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetWeights(weights),
+	})
+}
+
+func modelMigration(version int, name, collection string, model mongo.IndexModel) migration {
+	model.Options.SetName(name)
+
+	return migration{
+		version: version,
+		name:    name,
+		up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(collection).Indexes().CreateOne(ctx, model)
+			return err
+		},
+		down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(collection).Indexes().DropOne(ctx, name)
+			return err
+		},
+	}
+}
+
+type appliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// MigrationStatus describes a known migration. AppliedAt is nil for
+// pending migrations.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// MigrateUp applies all pending migrations in version order.
+func (db *DB) MigrateUp(ctx context.Context) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+
+		if err := m.up(ctx, db.database); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+
+		_, err := db.database.Collection(collectionMigrations).InsertOne(ctx, appliedMigration{
+			Version:   m.version,
+			Name:      m.name,
+			AppliedAt: time.Now(),
+		})
+		// Another instance may have applied it concurrently; creating
+		// the same index twice is a no-op.
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+
+		log.Info().Int("version", m.version).Str("name", m.name).Msg("Applied mongo migration")
+	}
+
+	return nil
+}
+
+// MigrateDown reverts the latest applied migration.
+func (db *DB) MigrateDown(ctx context.Context) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+
+		if err := m.down(ctx, db.database); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.version, m.name, err)
+		}
+
+		if _, err := db.database.Collection(collectionMigrations).DeleteOne(ctx, bson.M{"_id": m.version}); err != nil {
+			return err
+		}
+
+		log.Info().Int("version", m.version).Str("name", m.name).Msg("Reverted mongo migration")
+
+		return nil
+	}
+
+	return ErrNoMigrations
+}
+
+// MigrationStatus lists known migrations in version order.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{
+			Version: m.version,
+			Name:    m.name,
+		}
+		if a, ok := applied[m.version]; ok {
+			status.AppliedAt = &a.AppliedAt
+		}
+
+		result = append(result, status)
+	}
+
+	return result, nil
+}
+
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	cur, err := db.database.Collection(collectionMigrations).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var records []appliedMigration
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]appliedMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Cart keeps items by their hex IDs, so a single item can be set or
+// unset without reading the cart first.
+type Cart struct {
+	UserID    primitive.ObjectID  `bson:"_id"`
+	Items     map[string]CartItem `bson:"items"`
+	Version   int64               `bson:"version"`
+	UpdatedAt time.Time           `bson:"updated_at"`
+}
+
+type CartItem struct {
+	Quantity int64     `bson:"quantity"`
+	AddedAt  time.Time `bson:"added_at"`
+}
+
+// ConvertToDomain returns cart items in the order they were added.
+func (c *Cart) ConvertToDomain() *domain.Cart {
+	res := &domain.Cart{
+		UserID:    c.UserID.Hex(),
+		Items:     make([]domain.CartItem, 0, len(c.Items)),
+		Version:   c.Version,
+		UpdatedAt: c.UpdatedAt,
+	}
+
+	for id, it := range c.Items {
+		res.Items = append(res.Items, domain.CartItem{
+			ItemID:   id,
+			Quantity: it.Quantity,
+			AddedAt:  it.AddedAt,
+		})
+	}
+
+	sort.Slice(res.Items, func(i, j int) bool {
+		a, b := res.Items[i], res.Items[j]
+		if !a.AddedAt.Equal(b.AddedAt) {
+			return a.AddedAt.Before(b.AddedAt)
+		}
+
+		return a.ItemID < b.ItemID
+	})
+
+	return res
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+type IdempotencyRecord struct {
+	Key         string              `bson:"_id"`
+	Fingerprint string              `bson:"fingerprint"`
+	Response    *IdempotentResponse `bson:"response,omitempty"`
+	ExpiresAt   time.Time           `bson:"expires_at"`
+}
+
+type IdempotentResponse struct {
+	Status      int    `bson:"status"`
+	ContentType string `bson:"content_type"`
+	Body        []byte `bson:"body"`
+}
+
+func ConvertIdempotencyRecordFromDomain(rec *domain.IdempotencyRecord) *IdempotencyRecord {
+	res := &IdempotencyRecord{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		ExpiresAt:   rec.ExpiresAt,
+	}
+	if rec.Response != nil {
+		res.Response = &IdempotentResponse{
+			Status:      rec.Response.Status,
+			ContentType: rec.Response.ContentType,
+			Body:        rec.Response.Body,
+		}
+	}
+
+	return res
+}
+
+func (rec *IdempotencyRecord) ConvertToDomain() *domain.IdempotencyRecord {
+	res := &domain.IdempotencyRecord{
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		ExpiresAt:   rec.ExpiresAt,
+	}
+	if rec.Response != nil {
+		res.Response = &domain.IdempotentResponse{
+			Status:      rec.Response.Status,
+			ContentType: rec.Response.ContentType,
+			Body:        rec.Response.Body,
+		}
+	}
+
+	return res
+}
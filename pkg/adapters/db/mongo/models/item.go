@@ -15,11 +15,30 @@ type Item struct {
 	Name        string             `bson:"name"`
 	Description string             `bson:"desc"`
 	Category    string             `bson:"category"`
-	Price       float64            `bson:"price"`
+	Price       Money              `bson:"price"`
 	CreatedAt   time.Time          `bson:"created_at"`
 	Quantity    uint64             `bson:"quantity"`
 }
 
+// CategoryCount is a result of grouping items by category.
+type CategoryCount struct {
+	Category string `bson:"_id"`
+	Count    int64  `bson:"count"`
+}
+
+// PriceBucketCount is a result of bucketing items by price. Bound is the
+// lower bound of the bucket, or "other" for prices out of all buckets.
+type PriceBucketCount struct {
+	Bound interface{} `bson:"_id"`
+	Count int64       `bson:"count"`
+}
+
+// ScoredItem is an item found by a text search with its relevance.
+type ScoredItem struct {
+	Item  `bson:",inline"`
+	Score float64 `bson:"score"`
+}
+
 func ConvertItemFromDomainRequest(it *domain.AddItemRequest) (*Item, error) {
 	if it == nil {
 		return nil, domain.ErrNoItem
@@ -36,7 +55,7 @@ func ConvertItemFromDomainRequest(it *domain.AddItemRequest) (*Item, error) {
 		Name:        it.Name,
 		Description: it.Description,
 		Category:    it.Category,
-		Price:       it.Price,
+		Price:       ConvertMoneyFromDomain(it.Price),
 		CreatedAt:   time.Now(),
 		Quantity:    it.Quantity,
 	}, nil
@@ -59,7 +78,7 @@ func ConvertItemFromDomain(it *domain.Item) (*Item, error) {
 		Name:        it.Name,
 		Description: it.Description,
 		Category:    it.Category,
-		Price:       it.Price,
+		Price:       ConvertMoneyFromDomain(it.Price),
 		CreatedAt:   it.CreatedAt,
 		Quantity:    it.Quantity,
 	}, nil
@@ -72,7 +91,7 @@ func (it *Item) ConvertToDomain() *domain.Item {
 		Name:        it.Name,
 		Description: it.Description,
 		Category:    it.Category,
-		Price:       it.Price,
+		Price:       it.Price.ConvertToDomain(),
 		CreatedAt:   it.CreatedAt,
 		Quantity:    it.Quantity,
 	}
@@ -103,14 +122,62 @@ func ConvertUpdateReqToBSON(in *domain.UpdateItemRequest) (bson.M, error) {
 		req["category"] = in.Category
 	}
 	if in.OwnerID != nil {
-		req["owner_id"] = in.OwnerID
+		ownerID, err := primitive.ObjectIDFromHex(*in.OwnerID)
+		if err != nil {
+			return nil, domain.ErrInvalidId
+		}
+		req["owner_id"] = ownerID
 	}
 	if in.Price != nil {
-		req["price"] = in.Price
+		req["price"] = ConvertMoneyFromDomain(*in.Price)
 	}
 	if in.Quantity != nil {
 		req["quantity"] = in.Quantity
 	}
+	if len(req) == 0 {
+		return nil, domain.ErrNoUpdate
+	}
 	req = bson.M{"$set": req}
 	return req, nil
 }
+
+// ConvertItemFilterToBSON returns a query matching items selected by the
+// filter.
+func ConvertItemFilterToBSON(filter *domain.ItemFilter) (bson.M, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := bson.M{}
+	if filter.OwnerID != "" {
+		ownerID, err := primitive.ObjectIDFromHex(filter.OwnerID)
+		if err != nil {
+			return nil, domain.ErrInvalidId
+		}
+		query["owner_id"] = ownerID
+	}
+	if len(filter.Categories) > 0 {
+		query["category"] = bson.M{"$in": filter.Categories}
+	}
+	if currency := filter.PriceCurrency(); currency != "" {
+		query["price.currency"] = currency
+	}
+	amount := bson.M{}
+	if filter.MinPrice != nil {
+		amount["$gte"] = filter.MinPrice.Amount
+	}
+	if filter.MaxPrice != nil {
+		amount["$lte"] = filter.MaxPrice.Amount
+	}
+	if len(amount) > 0 {
+		query["price.amount"] = amount
+	}
+	if filter.InStock {
+		query["quantity"] = bson.M{"$gt": 0}
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query["created_at"] = bson.M{"$gte": filter.CreatedAfter}
+	}
+
+	return query, nil
+}
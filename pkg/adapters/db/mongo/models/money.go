@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Money keeps amounts as integer minor units so that aggregation
+// pipelines never touch floating point numbers.
+type Money struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+func ConvertMoneyFromDomain(m domain.Money) Money {
+	return Money{
+		Amount:   m.Amount,
+		Currency: m.Currency,
+	}
+}
+
+func (m Money) ConvertToDomain() domain.Money {
+	return domain.NewMoney(m.Amount, m.Currency)
+}
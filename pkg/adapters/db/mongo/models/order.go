@@ -12,16 +12,26 @@ import (
 type OrderItem struct {
 	ID       primitive.ObjectID `bson:"item_id"`
 	Quantity uint64             `bson:"quantity"`
+	Price    Money              `bson:"price"`
+	SellerID primitive.ObjectID `bson:"seller_id"`
+}
+
+type StatusTransition struct {
+	From  domain.StatusID `bson:"from"`
+	To    domain.StatusID `bson:"to"`
+	At    time.Time       `bson:"at"`
+	Actor string          `bson:"actor"`
 }
 
 type Order struct {
 	Items      []OrderItem        `bson:"items"`
 	CustomerID primitive.ObjectID `bson:"customer_id"`
 
-	ID        primitive.ObjectID `bson:"_id"`
-	Total     float64            `bson:"total"`
-	CreatedAt time.Time          `bson:"created_at"`
-	Status    domain.StatusID    `bson:"status"`
+	ID          primitive.ObjectID `bson:"_id"`
+	Total       Money              `bson:"total"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	Status      domain.StatusID    `bson:"status"`
+	Transitions []StatusTransition `bson:"transitions"`
 }
 
 // `bson:"-"`
@@ -36,7 +46,7 @@ func ConvertAddOrderRequestFromDomain(ord *domain.CreateOrderRequest) (*Order, e
 	for _, it := range ord.Items {
 		obj, err := primitive.ObjectIDFromHex(it.ID)
 		if err != nil {
-			return nil, err
+			return nil, domain.ErrInvalidId
 		}
 
 		itemIDs = append(itemIDs, OrderItem{
@@ -51,12 +61,12 @@ func ConvertAddOrderRequestFromDomain(ord *domain.CreateOrderRequest) (*Order, e
 	}
 
 	return &Order{
-		Items:      itemIDs,
-		CustomerID: customer,
-		ID:         primitive.NewObjectID(),
-		Total:      0,
-		CreatedAt:  time.Now(),
-		Status:     domain.CREATED,
+		Items:       itemIDs,
+		CustomerID:  customer,
+		ID:          primitive.NewObjectID(),
+		CreatedAt:   time.Now(),
+		Status:      domain.CREATED,
+		Transitions: []StatusTransition{},
 	}, nil
 }
 
@@ -72,7 +82,7 @@ func ConvertUpdateOrderReqToBSON(ord *domain.UpdateOrderRequest) (bson.M, error)
 		for _, it := range *ord.Items {
 			obj, err := primitive.ObjectIDFromHex(it.ID)
 			if err != nil {
-				return nil, err
+				return nil, domain.ErrInvalidId
 			}
 
 			itemIDs = append(itemIDs, OrderItem{
@@ -81,13 +91,17 @@ func ConvertUpdateOrderReqToBSON(ord *domain.UpdateOrderRequest) (bson.M, error)
 			})
 		}
 
-		req["item_ids"] = itemIDs
+		req["items"] = itemIDs
 	}
 
 	if ord.Status != nil {
 		req["status"] = *ord.Status
 	}
 
+	if len(req) == 0 {
+		return nil, domain.ErrNoUpdate
+	}
+
 	req = bson.M{"$set": req}
 	return req, nil
 }
@@ -95,18 +109,58 @@ func ConvertUpdateOrderReqToBSON(ord *domain.UpdateOrderRequest) (bson.M, error)
 func (o *Order) ConvertToDomain() *domain.Order {
 	items := make([]domain.OrderItem, 0, len(o.Items))
 	for _, it := range o.Items {
-		items = append(items, domain.OrderItem{
+		item := domain.OrderItem{
 			ID:       it.ID.Hex(),
 			Quantity: int64(it.Quantity),
-		})
+			Price:    it.Price.ConvertToDomain(),
+		}
+		if !it.SellerID.IsZero() {
+			item.SellerID = it.SellerID.Hex()
+		}
+
+		items = append(items, item)
+	}
+
+	transitions := make([]domain.StatusTransition, 0, len(o.Transitions))
+	for _, tr := range o.Transitions {
+		transitions = append(transitions, tr.ConvertToDomain())
 	}
 
 	return &domain.Order{
-		ID:         o.ID.Hex(),
-		Total:      o.Total,
-		Items:      items,
-		CreatedAt:  o.CreatedAt,
-		Status:     o.Status,
-		CustomerID: o.CustomerID.Hex(),
+		ID:          o.ID.Hex(),
+		Total:       o.Total.ConvertToDomain(),
+		Items:       items,
+		CreatedAt:   o.CreatedAt,
+		Status:      o.Status,
+		CustomerID:  o.CustomerID.Hex(),
+		Transitions: transitions,
 	}
 }
+
+func ConvertStatusTransitionFromDomain(tr *domain.StatusTransition) *StatusTransition {
+	return &StatusTransition{
+		From:  tr.From,
+		To:    tr.To,
+		At:    tr.At,
+		Actor: tr.Actor,
+	}
+}
+
+func (tr *StatusTransition) ConvertToDomain() domain.StatusTransition {
+	return domain.StatusTransition{
+		From:  tr.From,
+		To:    tr.To,
+		At:    tr.At,
+		Actor: tr.Actor,
+	}
+}
+
+func ConvertOrdersToDomain(orders []Order) []*domain.Order {
+	result := make([]*domain.Order, 0, len(orders))
+
+	for _, ord := range orders {
+		result = append(result, ord.ConvertToDomain())
+	}
+
+	return result
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+type Transaction struct {
+	ID        primitive.ObjectID     `bson:"_id"`
+	UserID    primitive.ObjectID     `bson:"user_id"`
+	OrderID   primitive.ObjectID     `bson:"order_id,omitempty"`
+	Kind      domain.TransactionKind `bson:"kind"`
+	Amount    Money                  `bson:"amount"`
+	CreatedAt time.Time              `bson:"created_at"`
+}
+
+func ConvertTransactionFromDomainRequest(req *domain.PostTransactionRequest) (*Transaction, error) {
+	userID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return nil, domain.ErrInvalidId
+	}
+
+	var orderID primitive.ObjectID
+	if req.OrderID != "" {
+		orderID, err = primitive.ObjectIDFromHex(req.OrderID)
+		if err != nil {
+			return nil, domain.ErrInvalidId
+		}
+	}
+
+	return &Transaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		OrderID:   orderID,
+		Kind:      req.Kind,
+		Amount:    ConvertMoneyFromDomain(req.Amount),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (tx *Transaction) ConvertToDomain() *domain.Transaction {
+	res := &domain.Transaction{
+		ID:        tx.ID.Hex(),
+		UserID:    tx.UserID.Hex(),
+		Kind:      tx.Kind,
+		Amount:    tx.Amount.ConvertToDomain(),
+		CreatedAt: tx.CreatedAt,
+	}
+	if !tx.OrderID.IsZero() {
+		res.OrderID = tx.OrderID.Hex()
+	}
+
+	return res
+}
+
+func ConvertTransactionsToDomain(txs []Transaction) []*domain.Transaction {
+	result := make([]*domain.Transaction, 0, len(txs))
+
+	for _, tx := range txs {
+		result = append(result, tx.ConvertToDomain())
+	}
+
+	return result
+}
@@ -14,7 +14,11 @@ type User struct {
 	Email     string             `bson:"email"`
 	Phone     string             `bson:"phone"`
 	CreatedAt time.Time          `bson:"created_at"`
-	Balance   uint64             `bson:"balance"`
+	Balance   Money              `bson:"balance"`
+	Roles     []string           `bson:"roles,omitempty"`
+	// PasswordHash is never converted to domain users, credentials are
+	// read separately.
+	PasswordHash []byte `bson:"password_hash,omitempty"`
 }
 
 func (user *User) ConvertToDomain() *domain.User {
@@ -24,10 +28,22 @@ func (user *User) ConvertToDomain() *domain.User {
 		Email:     user.Email,
 		Phone:     user.Phone,
 		CreatedAt: user.CreatedAt,
-		Balance:   user.Balance,
+		Balance:   user.Balance.ConvertToDomain(),
+		Roles:     convertRolesToDomain(user.Roles),
 	}
 }
 
+func convertRolesToDomain(roles []string) []domain.Role {
+	result := make([]domain.Role, 0, len(roles))
+	for _, role := range roles {
+		result = append(result, domain.Role(role))
+	}
+
+	domain.SortRoles(result)
+
+	return result
+}
+
 func ConvertUserFromDomain(user *domain.RegisterUserRequest) *User {
 	return &User{
 		ID:        primitive.NewObjectID(),
@@ -35,7 +51,9 @@ func ConvertUserFromDomain(user *domain.RegisterUserRequest) *User {
 		Email:     user.Email,
 		Phone:     user.Phone,
 		CreatedAt: time.Now(),
-		Balance:   0,
+		Balance:   ConvertMoneyFromDomain(domain.NewMoney(0, domain.DefaultCurrency)),
+
+		PasswordHash: user.PasswordHash,
 	}
 }
 
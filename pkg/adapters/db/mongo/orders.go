@@ -3,12 +3,14 @@ package mongo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Pavel7004/Common/tracing"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
 	"github.com/Pavel7004/WebShop/pkg/domain"
@@ -26,87 +28,155 @@ func (db *DB) CreateOrder(ctx context.Context, reqDom *domain.CreateOrderRequest
 		return "", err
 	}
 
-	itemIDs := make([]primitive.ObjectID, len(req.Items))
-	itemQuantities := make([]uint64, len(req.Items))
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
 
-	for i, item := range req.Items {
-		itemIDs[i] = item.ID
-		itemQuantities[i] = item.Quantity
-	}
-	log.Debug().Msgf("%v ;; %v", itemIDs, itemQuantities)
-
-	pipeline := mongo.Pipeline{
-		// Get database items that are presented in order request.
-		{primitive.E{Key: "$match", Value: bson.M{"_id": bson.M{"$in": itemIDs}}}},
-		// Populate documents with bought goods amount.
-		// $indexOfArray based on record's ID returning an index from itemIDs,
-		// which is the same as the index from itemQuantities.
-		{
-			primitive.E{
-				Key: "$addFields",
-				Value: bson.M{
-					"quantity_bought": bson.M{
-						"$arrayElemAt": bson.A{
-							itemQuantities,
-							bson.M{"$indexOfArray": bson.A{itemIDs, "$_id"}},
-						},
-					},
-				},
-			},
-		},
-		// Calculate final price.
-		{
-			primitive.E{
-				Key: "$group",
-				Value: bson.M{
-					"_id": nil,
-					"total": bson.M{
-						"$sum": bson.M{"$multiply": bson.A{"$price", "$quantity_bought"}},
-					},
-				},
-			},
-		},
-	}
-	cursor, err := db.collectionItems.Aggregate(ctx, pipeline)
+	session, err := db.client.StartSession()
 	if err != nil {
 		span.SetTag("error", true)
 		span.LogKV("event", "error", "message", err.Error())
-		log.Error().Err(err).Msg("Error in aggregation")
+		log.Error().Err(err).Msg("Error in StartSession")
 		return "", err
 	}
-	defer cursor.Close(ctx)
+	defer session.EndSession(ctx)
 
-	log.Debug().Msgf("Cursor = %v", cursor)
-	var result struct {
-		Total float64 `bson:"total"`
-	}
-	if err := cursor.Decode(&result); err != nil {
+	// Stock reservation, pricing, order insertion and clearing of the
+	// cart ordered from either all happen or none of them do.
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if err := db.reserveStock(sc, req.Items); err != nil {
+			return nil, err
+		}
+
+		total, err := db.priceItems(sc, req.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Total = total
+
+		if reqDom.CartVersion != 0 {
+			if err := db.checkoutCart(sc, req.CustomerID, reqDom.CartVersion); err != nil {
+				return nil, err
+			}
+		}
+
+		return db.collectionOrders.InsertOne(sc, req)
+	})
+	if err != nil {
 		span.SetTag("error", true)
 		span.LogKV("event", "error", "message", err.Error())
-		log.Error().Err(err).Msg("Error in Decode")
+		log.Error().Err(err).Msg("Error in order transaction")
 		return "", err
 	}
 
-	req.Total = result.Total
-	res, err := db.collectionOrders.InsertOne(ctx, req)
+	span.SetTag("result_id", req.ID.Hex())
+
+	return req.ID.Hex(), nil
+}
+
+// reserveStock decrements the quantity of every ordered item. Items that
+// don't have enough stock are collected into ErrInsufficientStock.
+func (db *DB) reserveStock(ctx context.Context, items []models.OrderItem) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var short []string
+
+	for _, item := range items {
+		res, err := db.collectionItems.UpdateOne(
+			ctx,
+			bson.M{"_id": item.ID, "quantity": bson.M{"$gte": item.Quantity}},
+			bson.M{"$inc": bson.M{"quantity": -int64(item.Quantity)}},
+		)
+		if err != nil {
+			return err
+		}
+
+		if res.MatchedCount == 0 {
+			short = append(short, item.ID.Hex())
+		}
+	}
+
+	if len(short) > 0 {
+		span.SetTag("short_items", short)
+		return domain.NewInsufficientStockError(short)
+	}
+
+	return nil
+}
+
+// releaseStock returns the reserved quantity of every ordered item.
+func (db *DB) releaseStock(ctx context.Context, items []models.OrderItem) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	for _, item := range items {
+		_, err := db.collectionItems.UpdateOne(
+			ctx,
+			bson.M{"_id": item.ID},
+			bson.M{"$inc": bson.M{"quantity": int64(item.Quantity)}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// priceItems records the current price and seller of every ordered item
+// and returns the order total. Every line is priced on its own, so an
+// item listed twice is charged for both lines, just like its stock is
+// reserved for both. All items of an order must be priced in the same
+// currency.
+func (db *DB) priceItems(ctx context.Context, items []models.OrderItem) (models.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	itemIDs := make([]primitive.ObjectID, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+
+	cursor, err := db.collectionItems.Find(ctx, bson.M{"_id": bson.M{"$in": itemIDs}},
+		options.Find().SetProjection(bson.M{"owner_id": 1, "price": 1}))
 	if err != nil {
-		span.SetTag("error", true)
-		span.LogKV("event", "error", "message", err.Error())
-		log.Error().Err(err).Msg("Error in InsertOne")
-		return "", err
+		return models.Money{}, err
 	}
+	defer cursor.Close(ctx)
 
-	obj, ok := res.InsertedID.(primitive.ObjectID)
-	if !ok {
-		span.SetTag("error", true)
-		span.LogKV("event", "error", "message", domain.ErrInvalidId.Error())
-		log.Error().Err(domain.ErrInvalidId).Msg("Result isn't object id.")
-		return "", domain.ErrInvalidId
+	var found []struct {
+		ID      primitive.ObjectID `bson:"_id"`
+		OwnerID primitive.ObjectID `bson:"owner_id"`
+		Price   models.Money       `bson:"price"`
 	}
+	if err := cursor.All(ctx, &found); err != nil {
+		return models.Money{}, err
+	}
+
+	total := domain.Money{}
+	for _, it := range found {
+		for i := range items {
+			if items[i].ID != it.ID {
+				continue
+			}
 
-	span.SetTag("result_id", obj.Hex())
+			items[i].Price = it.Price
+			items[i].SellerID = it.OwnerID
 
-	return obj.Hex(), nil
+			subtotal, err := it.Price.ConvertToDomain().Mul(int64(items[i].Quantity))
+			if err != nil {
+				return models.Money{}, err
+			}
+
+			total, err = total.Add(subtotal)
+			if err != nil {
+				return models.Money{}, err
+			}
+		}
+	}
+
+	return models.ConvertMoneyFromDomain(total), nil
 }
 
 func (db *DB) GetOrderInfo(ctx context.Context, id string) (*domain.Order, error) {
@@ -122,7 +192,7 @@ func (db *DB) GetOrderInfo(ctx context.Context, id string) (*domain.Order, error
 	defer cancel()
 
 	var result models.Order
-	if err := db.collectionItems.FindOne(ctx, bson.M{"_id": obj}).Decode(&result); err != nil {
+	if err := db.collectionOrders.FindOne(ctx, bson.M{"_id": obj}).Decode(&result); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, domain.ErrOrderNotFound
 		}
@@ -133,6 +203,73 @@ func (db *DB) GetOrderInfo(ctx context.Context, id string) (*domain.Order, error
 	return result.ConvertToDomain(), nil
 }
 
+func (db *DB) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("customer_id", id)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	customerID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrInvalidId
+	}
+
+	filter, options, err := pageQuery(bson.M{"customer_id": customerID}, "created_at", page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	cur, err := db.collectionOrders.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var results []models.Order
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return domain.NewOrdersPage(models.ConvertOrdersToDomain(results), page), nil
+}
+
+func (db *DB) GetUnpaidOrderIds(ctx context.Context, createdBefore time.Time, limit int64) ([]string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("created_before", createdBefore)
+	span.SetTag("limit", limit)
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	cur, err := db.collectionOrders.Find(ctx,
+		bson.M{"status": domain.CREATED, "created_at": bson.M{"$lt": createdBefore}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetLimit(limit).SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(found))
+	for i, order := range found {
+		ids[i] = order.ID.Hex()
+	}
+
+	return ids, nil
+}
+
 func (db *DB) UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrderRequest) error {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
@@ -147,18 +284,268 @@ func (db *DB) UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrder
 		return domain.ErrInvalidId
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
 	res, err := db.collectionOrders.UpdateByID(ctx, obj, req)
 	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount < 1 {
+		return domain.ErrOrderNotFound
+	}
+
+	return nil
+}
+
+func (db *DB) TransitionOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+	span.SetTag("from", string(tr.From))
+	span.SetTag("to", string(tr.To))
+
+	obj, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	if !tr.ReleasesStock() {
+		return db.transitOrder(ctx, obj, tr)
+	}
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	// The status is changed first, so the stock of an order is returned
+	// only once however many transitions race.
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if err := db.transitOrder(sc, obj, tr); err != nil {
+			return nil, err
+		}
+
+		var order models.Order
+		if err := db.collectionOrders.FindOne(sc, bson.M{"_id": obj}).Decode(&order); err != nil {
+			return nil, err
+		}
+
+		return nil, db.releaseStock(sc, order.Items)
+	})
+
+	return err
+}
+
+func (db *DB) PayOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	return db.settleOrder(ctx, id, tr, db.chargeOrder)
+}
+
+func (db *DB) RefundOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	return db.settleOrder(ctx, id, tr, db.refundOrder)
+}
+
+// settleOrder runs the money movement for the order and applies the
+// transition in one transaction.
+func (db *DB) settleOrder(
+	ctx context.Context,
+	id string,
+	tr domain.StatusTransition,
+	move func(ctx context.Context, order *models.Order) error,
+) error {
+	obj, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var order models.Order
+		if err := db.collectionOrders.FindOne(sc, bson.M{"_id": obj}).Decode(&order); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, domain.ErrOrderNotFound
+			}
+
+			return nil, err
+		}
+
+		if order.Status != tr.From {
+			return nil, domain.ErrOrderStatusConflict
+		}
+
+		if err := move(sc, &order); err != nil {
+			return nil, err
+		}
+
+		return nil, db.transitOrder(sc, obj, tr)
+	})
+
+	return err
+}
+
+// chargeOrder debits the order total from the customer and pays every
+// seller for their lines.
+func (db *DB) chargeOrder(ctx context.Context, order *models.Order) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var customer models.User
+	if err := db.collectionUsers.FindOne(ctx, bson.M{"_id": order.CustomerID}).Decode(&customer); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return domain.ErrItemNotFound
+			return domain.ErrUserNotFound
+		}
+
+		return err
+	}
+
+	total := order.Total.ConvertToDomain()
+
+	short, err := customer.Balance.ConvertToDomain().Less(total)
+	if err != nil {
+		return err
+	}
+
+	if short {
+		return domain.ErrInsufficientFunds
+	}
+
+	err = db.postTransaction(ctx, newOrderTransaction(customer.ID, order.ID, domain.PAYMENT, total.Neg()))
+	if err != nil {
+		return err
+	}
+
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	for sellerID, amount := range shares {
+		err := db.postTransaction(ctx, newOrderTransaction(sellerID, order.ID, domain.PAYOUT, amount))
+		if err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
 
+// refundOrder returns the order total to the customer and takes the
+// payouts back from the sellers.
+func (db *DB) refundOrder(ctx context.Context, order *models.Order) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	total := order.Total.ConvertToDomain()
+
+	err := db.postTransaction(ctx, newOrderTransaction(order.CustomerID, order.ID, domain.REFUND, total))
+	if err != nil {
 		return err
 	}
 
-	if res.ModifiedCount < 1 {
-		return domain.ErrItemNotFound
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	for sellerID, amount := range shares {
+		err := db.postTransaction(ctx, newOrderTransaction(sellerID, order.ID, domain.REFUND, amount.Neg()))
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+func sellerShares(items []models.OrderItem) (map[primitive.ObjectID]domain.Money, error) {
+	shares := map[primitive.ObjectID]domain.Money{}
+	for _, item := range items {
+		if item.SellerID.IsZero() {
+			continue
+		}
+
+		share, err := item.Price.ConvertToDomain().Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, err
+		}
+
+		if prev, ok := shares[item.SellerID]; ok {
+			// Lines were priced in one currency when the order was placed.
+			share, err = prev.Add(share)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		shares[item.SellerID] = share
+	}
+
+	return shares, nil
+}
+
+func newOrderTransaction(userID, orderID primitive.ObjectID, kind domain.TransactionKind, amount domain.Money) *models.Transaction {
+	return &models.Transaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		OrderID:   orderID,
+		Kind:      kind,
+		Amount:    models.ConvertMoneyFromDomain(amount),
+		CreatedAt: time.Now(),
+	}
+}
+
+// transitOrder moves the order to tr.To only if it's still in tr.From.
+// Matching on the current status makes the update a compare-and-swap,
+// so concurrent transitions can't both succeed.
+func (db *DB) transitOrder(ctx context.Context, id primitive.ObjectID, tr domain.StatusTransition) error {
+	res, err := db.collectionOrders.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "status": tr.From},
+		bson.M{
+			"$set":  bson.M{"status": tr.To},
+			"$push": bson.M{"transitions": models.ConvertStatusTransitionFromDomain(&tr)},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	count, err := db.collectionOrders.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return domain.ErrOrderNotFound
+	}
+
+	return domain.ErrOrderStatusConflict
+}
@@ -0,0 +1,246 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Pavel7004/Common/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) PostTransaction(ctx context.Context, req *domain.PostTransactionRequest) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", req.UserID)
+	span.SetTag("kind", string(req.Kind))
+	span.SetTag("amount", req.Amount.String())
+
+	tx, err := models.ConvertTransactionFromDomainRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, db.postTransaction(sc, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", tx.ID.Hex())
+
+	return tx.ConvertToDomain(), nil
+}
+
+func (db *DB) Withdraw(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("amount", amount.String())
+
+	tx, err := models.ConvertTransactionFromDomainRequest(&domain.PostTransactionRequest{
+		UserID: userID,
+		Kind:   domain.WITHDRAWAL,
+		Amount: amount.Neg(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var user models.User
+		if err := db.collectionUsers.FindOne(sc, bson.M{"_id": tx.UserID}).Decode(&user); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, domain.ErrUserNotFound
+			}
+
+			return nil, err
+		}
+
+		short, err := user.Balance.ConvertToDomain().Less(amount)
+		if err != nil {
+			return nil, err
+		}
+
+		if short {
+			return nil, domain.ErrInsufficientFunds
+		}
+
+		return nil, db.postTransaction(sc, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", tx.ID.Hex())
+
+	return tx.ConvertToDomain(), nil
+}
+
+func (db *DB) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	obj, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, domain.ErrInvalidId
+	}
+
+	filter, options, err := pageQuery(bson.M{"user_id": obj}, "created_at", page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	cur, err := db.collectionTransactions.Find(ctx, filter, options)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var result []models.Transaction
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return domain.NewTransactionsPage(models.ConvertTransactionsToDomain(result), page), nil
+}
+
+func (db *DB) RecomputeBalance(ctx context.Context, userID string) (domain.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	obj, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return domain.Money{}, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return domain.Money{}, err
+	}
+	defer session.EndSession(ctx)
+
+	balance, err := session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var user models.User
+		if err := db.collectionUsers.FindOne(sc, bson.M{"_id": obj}).Decode(&user); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, domain.ErrUserNotFound
+			}
+
+			return nil, err
+		}
+
+		pipeline := mongo.Pipeline{
+			{primitive.E{Key: "$match", Value: bson.M{"user_id": obj}}},
+			{primitive.E{Key: "$group", Value: bson.M{"_id": nil, "balance": bson.M{"$sum": "$amount.amount"}}}},
+		}
+		cursor, err := db.collectionTransactions.Aggregate(sc, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(sc)
+
+		var result struct {
+			Balance int64 `bson:"balance"`
+		}
+		if cursor.Next(sc) {
+			if err := cursor.Decode(&result); err != nil {
+				return nil, err
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+
+		balance := models.Money{
+			Amount:   result.Balance,
+			Currency: user.Balance.Currency,
+		}
+
+		_, err = db.collectionUsers.UpdateByID(sc, obj, bson.M{"$set": bson.M{"balance": balance}})
+		if err != nil {
+			return nil, err
+		}
+
+		return balance.ConvertToDomain(), nil
+	})
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	span.SetTag("balance", balance.(domain.Money).String())
+
+	return balance.(domain.Money), nil
+}
+
+// postTransaction appends the entry to the ledger and applies it to the
+// user's balance. It must be called inside a session transaction.
+func (db *DB) postTransaction(ctx context.Context, tx *models.Transaction) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var user models.User
+	if err := db.collectionUsers.FindOne(ctx, bson.M{"_id": tx.UserID}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return domain.ErrUserNotFound
+		}
+
+		return err
+	}
+
+	if user.Balance.Currency != tx.Amount.Currency {
+		return domain.ErrCurrencyMismatch
+	}
+
+	// The transaction fails on a write conflict if the balance changes
+	// after it was read, so the sum checked here is the one stored.
+	if _, err := user.Balance.ConvertToDomain().Add(tx.Amount.ConvertToDomain()); err != nil {
+		return err
+	}
+
+	_, err := db.collectionUsers.UpdateByID(ctx, tx.UserID, bson.M{"$inc": bson.M{"balance.amount": tx.Amount.Amount}})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.collectionTransactions.InsertOne(ctx, tx)
+
+	return err
+}
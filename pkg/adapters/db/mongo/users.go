@@ -8,7 +8,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/Pavel7004/WebShop/pkg/adapters/db/mongo/models"
 	"github.com/Pavel7004/WebShop/pkg/domain"
@@ -22,8 +21,11 @@ func (db *DB) RegisterUser(ctx context.Context, user *domain.RegisterUserRequest
 	defer cancel()
 
 	res, err := db.collectionUsers.InsertOne(ctx, models.ConvertUserFromDomain(user))
-
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", domain.ErrEmailTaken
+		}
+
 		return "", err
 	}
 
@@ -63,40 +65,105 @@ func (db *DB) GetUserById(ctx context.Context, id string) (*domain.User, error)
 	return result.ConvertToDomain(), nil
 }
 
-func (db *DB) GetRecentlyAddedUsers(ctx context.Context, count int64) ([]*domain.User, error) {
+func (db *DB) FindUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("count", count)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
 
-	options := options.Find()
-	options.SetSort(bson.M{"$natural": -1})
-	options.SetLimit(count)
+	filter, options, err := pageQuery(bson.M{}, "created_at", page)
+	if err != nil {
+		return nil, err
+	}
 
-	cur, err := db.collectionUsers.Find(ctx, bson.M{}, options)
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	cur, err := db.collectionUsers.Find(ctx, filter, options)
 	if err != nil {
 		return nil, err
 	}
 	defer cur.Close(ctx)
 
-	var result []models.User
-	if err := cur.All(ctx, &result); err != nil {
+	users := make([]*domain.User, 0, page.Limit+1)
+	for cur.Next(ctx) {
+		var user models.User
+		if err := cur.Decode(&user); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user.ConvertToDomain())
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return domain.NewUsersPage(users, page), nil
+}
+
+func (db *DB) GetUserCredentials(ctx context.Context, email string) (*domain.Credentials, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("email", email)
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	var result models.User
+	if err := db.collectionUsers.FindOne(ctx, bson.M{"email": email}).Decode(&result); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrUserNotFound
+		}
+
 		return nil, err
 	}
 
-	return models.ConvertUsersToDomain(result), nil
+	return &domain.Credentials{
+		UserID:       result.ID.Hex(),
+		PasswordHash: result.PasswordHash,
+	}, nil
 }
 
-func (db *DB) GetItemsByOwnerId(ctx context.Context, id string) ([]*domain.Item, error) {
+func (db *DB) AddUserRole(ctx context.Context, id string, role domain.Role) error {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("owner_id", id)
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
+
+	return db.updateRoles(ctx, id, bson.M{"$addToSet": bson.M{"roles": string(role)}})
+}
+
+func (db *DB) RemoveUserRole(ctx context.Context, id string, role domain.Role) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
 
-	ownerID, err := primitive.ObjectIDFromHex(id)
+	return db.updateRoles(ctx, id, bson.M{"$pull": bson.M{"roles": string(role)}})
+}
+
+func (db *DB) updateRoles(ctx context.Context, id string, update bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, domain.ErrInvalidId
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.cfg.Timeout)
+	defer cancel()
+
+	res, err := db.collectionUsers.UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return domain.ErrUserNotFound
 	}
 
-	return db.findItems(ctx, bson.M{"owner_id": ownerID})
+	return nil
 }
@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/sqldb"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// uniqueViolation is the SQLSTATE of unique constraint violations.
+const uniqueViolation = "23505"
+
+// migrationsLockID serializes migrations of concurrently starting
+// instances through a transaction level advisory lock.
+const migrationsLockID = 0x73686f70
+
+var dialect = sqldb.Dialect{
+	Name:        "postgres",
+	Placeholder: "$",
+	ForUpdate:   " FOR UPDATE",
+	ILike:       "ILIKE",
+	NewID:       newID,
+	ValidID:     validID,
+	Time:        func(t time.Time) interface{} { return t },
+	UniqueViolation: func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+	},
+	Migrations: migrationsFS,
+	MigrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    integer PRIMARY KEY,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`,
+	LockMigrations: "SELECT pg_advisory_xact_lock(" + strconv.Itoa(migrationsLockID) + ")",
+}
+
+// New connects to PostgreSQL, applies pending migrations and prepares
+// statements used by the adapter.
+func New(cfg *config.Config) (*sqldb.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Postgres.Timeout)
+	defer cancel()
+
+	conn, err := sql.Open("pgx", cfg.Postgres.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqldb.New(ctx, conn, &dialect, cfg.Postgres.Timeout)
+}
+
+// newID returns a random UUID, version 4.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// validID reports whether id is a UUID in its canonical text form.
+func validID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+
+	for i, c := range id {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F'):
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/dbtest"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+// TestDB needs a PostgreSQL 13+ server. Every test gets its own schema,
+// which is dropped afterwards:
+//
+//	POSTGRES_TEST_DSN=postgres://postgres@localhost:5432/postgres?sslmode=disable go test ./...
+func TestDB(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN isn't set")
+	}
+
+	dbtest.Run(t, dbtest.Adapter{
+		New: func(t *testing.T) db.DB {
+			schema := fmt.Sprintf("shop_test_%d", time.Now().UnixNano())
+
+			admin, err := sql.Open("pgx", dsn)
+			if err != nil {
+				t.Fatalf("failed to connect: %v", err)
+			}
+			t.Cleanup(func() { admin.Close() })
+
+			if _, err := admin.ExecContext(context.Background(), "CREATE SCHEMA "+schema); err != nil {
+				t.Fatalf("failed to create test schema: %v", err)
+			}
+
+			d, err := New(&config.Config{
+				Postgres: config.PostgresCfg{
+					DSN:     withSearchPath(t, dsn, schema),
+					Timeout: 10 * time.Second,
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+
+			t.Cleanup(func() {
+				if err := d.Close(); err != nil {
+					t.Errorf("failed to close connection: %v", err)
+				}
+				if _, err := admin.ExecContext(context.Background(), "DROP SCHEMA "+schema+" CASCADE"); err != nil {
+					t.Errorf("failed to drop test schema: %v", err)
+				}
+			})
+
+			return d
+		},
+		UnknownID: "00000000-0000-0000-0000-000000000000",
+	})
+}
+
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("POSTGRES_TEST_DSN must be a URL: %v", err)
+	}
+
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
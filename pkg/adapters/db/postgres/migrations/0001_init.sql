@@ -0,0 +1,76 @@
+-- gen_random_uuid() is built in since PostgreSQL 13.
+
+CREATE TABLE users (
+    id               uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    seq              bigserial NOT NULL,
+    name             text NOT NULL,
+    email            text NOT NULL,
+    phone            text NOT NULL,
+    created_at       timestamptz NOT NULL,
+    balance_amount   bigint NOT NULL DEFAULT 0,
+    balance_currency text NOT NULL
+);
+
+CREATE INDEX users_seq_idx ON users (seq);
+
+CREATE TABLE items (
+    id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id       uuid NOT NULL,
+    name           text NOT NULL,
+    description    text NOT NULL,
+    category       text NOT NULL,
+    price_amount   bigint NOT NULL,
+    price_currency text NOT NULL,
+    created_at     timestamptz NOT NULL,
+    quantity       bigint NOT NULL CHECK (quantity >= 0)
+);
+
+CREATE INDEX items_price_idx ON items (price_currency, price_amount);
+CREATE INDEX items_created_at_idx ON items (created_at);
+CREATE INDEX items_owner_id_idx ON items (owner_id);
+
+CREATE TABLE orders (
+    id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    customer_id    uuid NOT NULL,
+    total_amount   bigint NOT NULL,
+    total_currency text NOT NULL,
+    created_at     timestamptz NOT NULL,
+    status         text NOT NULL
+);
+
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, created_at);
+
+CREATE TABLE order_items (
+    order_id       uuid NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    position       integer NOT NULL,
+    item_id        uuid NOT NULL,
+    quantity       bigint NOT NULL,
+    price_amount   bigint NOT NULL,
+    price_currency text NOT NULL,
+    seller_id      uuid,
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE TABLE order_transitions (
+    id          bigserial PRIMARY KEY,
+    order_id    uuid NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status text NOT NULL,
+    to_status   text NOT NULL,
+    at          timestamptz NOT NULL,
+    actor       text NOT NULL
+);
+
+CREATE INDEX order_transitions_order_id_idx ON order_transitions (order_id, id);
+
+CREATE TABLE transactions (
+    id         uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    seq        bigserial NOT NULL,
+    user_id    uuid NOT NULL REFERENCES users (id),
+    order_id   uuid,
+    kind       text NOT NULL,
+    amount     bigint NOT NULL,
+    currency   text NOT NULL,
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX transactions_user_id_idx ON transactions (user_id, created_at DESC, seq DESC);
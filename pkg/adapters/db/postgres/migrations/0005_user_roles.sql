@@ -0,0 +1,7 @@
+-- Roles granted to users. Buying needs no role, so most users have none.
+
+CREATE TABLE user_roles (
+    user_id uuid NOT NULL REFERENCES users (id),
+    role    text NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
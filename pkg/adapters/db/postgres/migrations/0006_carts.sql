@@ -0,0 +1,16 @@
+-- Clearing a cart deletes its items but keeps the cart row, so versions
+-- keep growing and a checkout of a stale cart can't clear a newer one.
+
+CREATE TABLE carts (
+    user_id    uuid PRIMARY KEY REFERENCES users (id),
+    version    bigint NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE TABLE cart_items (
+    user_id  uuid NOT NULL REFERENCES carts (user_id),
+    item_id  uuid NOT NULL REFERENCES items (id),
+    quantity bigint NOT NULL CHECK (quantity > 0),
+    added_at timestamptz NOT NULL,
+    PRIMARY KEY (user_id, item_id)
+);
@@ -0,0 +1,13 @@
+-- Requests made with idempotency keys. The response columns are NULL
+-- while a request is being processed.
+
+CREATE TABLE idempotency_keys (
+    key          text PRIMARY KEY,
+    fingerprint  text NOT NULL,
+    status       integer,
+    content_type text,
+    body         bytea,
+    expires_at   timestamptz NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
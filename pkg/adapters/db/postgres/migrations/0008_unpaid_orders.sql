@@ -0,0 +1,3 @@
+-- The expiry sweep looks for orders left unpaid for too long.
+
+CREATE INDEX orders_status_created_at_idx ON orders (status, created_at);
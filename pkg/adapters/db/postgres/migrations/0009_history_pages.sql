@@ -0,0 +1,8 @@
+-- Orders and transactions of a user are listed in pages sorted by
+-- creation time and then by ID.
+
+DROP INDEX orders_customer_id_idx;
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, created_at, id);
+
+DROP INDEX transactions_user_id_idx;
+CREATE INDEX transactions_user_id_idx ON transactions (user_id, created_at, id);
@@ -0,0 +1,172 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (db *DB) GetCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !db.validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.stmts.getCart.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cart := &domain.Cart{UserID: userID, Items: []domain.CartItem{}}
+	for rows.Next() {
+		var (
+			itemID   sql.NullString
+			quantity sql.NullInt64
+			addedAt  time.Time
+		)
+
+		if err := rows.Scan(&cart.Version, scanTime(&cart.UpdatedAt), &itemID, &quantity, scanTime(&addedAt)); err != nil {
+			return nil, err
+		}
+
+		// An empty cart is a single row without an item.
+		if itemID.Valid {
+			cart.Items = append(cart.Items, domain.CartItem{
+				ItemID:   itemID.String,
+				Quantity: quantity.Int64,
+				AddedAt:  addedAt,
+			})
+		}
+	}
+
+	return cart, rows.Err()
+}
+
+func (db *DB) SetCartItem(ctx context.Context, userID, itemID string, quantity, version int64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+	span.SetTag("quantity", quantity)
+	span.SetTag("cart_version", version)
+
+	if !db.validID(userID) || !db.validID(itemID) {
+		return domain.ErrInvalidId
+	}
+
+	if quantity <= 0 {
+		return domain.ErrCartQuantity
+	}
+
+	return db.changeCart(ctx, userID, func(tx *sql.Tx, now time.Time) error {
+		// The version was bumped already, so it's one more than the one
+		// of the cart the change was made to.
+		var current int64
+		if err := tx.StmtContext(ctx, db.stmts.cartVersion).QueryRowContext(ctx, userID).Scan(&current); err != nil {
+			return err
+		}
+
+		if current != version+1 {
+			return domain.ErrCartChanged
+		}
+
+		_, err := tx.StmtContext(ctx, db.stmts.setCartItem).ExecContext(ctx, userID, itemID, quantity, db.dialect.Time(now))
+		return err
+	})
+}
+
+func (db *DB) RemoveCartItem(ctx context.Context, userID, itemID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+
+	if !db.validID(userID) || !db.validID(itemID) {
+		return domain.ErrInvalidId
+	}
+
+	return db.changeCart(ctx, userID, func(tx *sql.Tx, _ time.Time) error {
+		res, err := tx.StmtContext(ctx, db.stmts.removeCartItem).ExecContext(ctx, userID, itemID)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return domain.ErrCartItemNotFound
+		}
+
+		return nil
+	})
+}
+
+func (db *DB) ClearCart(ctx context.Context, userID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !db.validID(userID) {
+		return domain.ErrInvalidId
+	}
+
+	return db.changeCart(ctx, userID, func(tx *sql.Tx, _ time.Time) error {
+		_, err := tx.StmtContext(ctx, db.stmts.clearCart).ExecContext(ctx, userID)
+		return err
+	})
+}
+
+// changeCart bumps the cart version and runs fn in the same transaction.
+// The version is bumped first, so the cart row stays locked while fn
+// changes the items where the dialect locks rows.
+func (db *DB) changeCart(ctx context.Context, userID string, fn func(tx *sql.Tx, now time.Time) error) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	now := time.Now()
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.StmtContext(ctx, db.stmts.touchCart).ExecContext(ctx, userID, db.dialect.Time(now)); err != nil {
+			return err
+		}
+
+		return fn(tx, now)
+	})
+}
+
+// checkoutCart clears the cart if it's still of the version the order
+// was built from.
+func (db *DB) checkoutCart(ctx context.Context, tx *sql.Tx, userID string, version int64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	res, err := tx.StmtContext(ctx, db.stmts.checkoutCart).ExecContext(ctx, userID, version, db.dialect.Time(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return domain.ErrCartChanged
+	}
+
+	_, err = tx.StmtContext(ctx, db.stmts.clearCart).ExecContext(ctx, userID)
+
+	return err
+}
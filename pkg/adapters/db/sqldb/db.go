@@ -0,0 +1,108 @@
+// Package sqldb implements the storage on top of database/sql. SQL
+// databases differ only in a Dialect, the postgres and sqlite packages
+// provide theirs.
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+)
+
+var _ db.DB = (*DB)(nil)
+
+type DB struct {
+	conn    *sql.DB
+	dialect *Dialect
+	timeout time.Duration
+	stmts   *statements
+}
+
+// New checks the connection, applies pending migrations and prepares
+// statements used by the adapter. The connection is closed if any of
+// it fails.
+func New(ctx context.Context, conn *sql.DB, dialect *Dialect, timeout time.Duration) (*DB, error) {
+	if err := conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := migrate(ctx, conn, dialect); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	stmts, err := prepareStatements(ctx, conn, dialect)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{
+		conn:    conn,
+		dialect: dialect,
+		timeout: timeout,
+		stmts:   stmts,
+	}, nil
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	return db.conn.PingContext(ctx)
+}
+
+func (db *DB) Close() error {
+	stmtErr := db.stmts.close()
+
+	if err := db.conn.Close(); err != nil {
+		return err
+	}
+
+	return stmtErr
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (db *DB) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *DB) validID(id string) bool {
+	return db.dialect.ValidID(id)
+}
+
+// list starts a list query in the dialect of the database.
+func (db *DB) list() *listQuery {
+	return &listQuery{dialect: db.dialect}
+}
+
+// querier runs queries either in a transaction or outside of one.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func nullID(id string) sql.NullString {
+	return sql.NullString{String: id, Valid: id != ""}
+}
+
+func exists(ctx context.Context, stmt *sql.Stmt, id string) (bool, error) {
+	var found bool
+	if err := stmt.QueryRowContext(ctx, id).Scan(&found); err != nil {
+		return false, err
+	}
+
+	return found, nil
+}
@@ -0,0 +1,87 @@
+package sqldb
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// Dialect describes how a database differs from the SQL the adapter is
+// written in. Statements use PostgreSQL placeholders and are rewritten
+// for the dialect before they are prepared.
+type Dialect struct {
+	// Name names the database in logs.
+	Name string
+
+	// Placeholder replaces "$" of numbered placeholders, so "?" turns
+	// "$1" into "?1".
+	Placeholder string
+
+	// ForUpdate is appended to queries reading rows the transaction is
+	// about to change. It's empty for databases that serialize whole
+	// transactions.
+	ForUpdate string
+
+	// ILike is the operator matching patterns regardless of case. It's
+	// empty if the database folds case of ASCII letters only, then search
+	// can't narrow items down and matches all of them in process.
+	ILike string
+
+	// NewID returns an identifier for a new row.
+	NewID func() string
+
+	// ValidID reports whether id has the format of NewID.
+	ValidID func(id string) bool
+
+	// Time converts a timestamp to the value stored in the database.
+	// Stored values are either time.Time or Unix time in nanoseconds.
+	Time func(t time.Time) interface{}
+
+	// UniqueViolation reports whether err is a unique constraint
+	// violation.
+	UniqueViolation func(err error) bool
+
+	// Migrations holds the schema migrations in the migrations directory,
+	// a NNNN_name.sql file each.
+	Migrations fs.FS
+
+	// MigrationsTable creates the table of applied migrations if it
+	// doesn't exist.
+	MigrationsTable string
+
+	// LockMigrations, if set, runs in the transaction of every migration
+	// to serialize migrations of concurrently starting instances.
+	LockMigrations string
+}
+
+// rebind rewrites placeholders of the query for the dialect. Queries of
+// the adapter have no "$" other than in placeholders.
+func (d *Dialect) rebind(query string) string {
+	return strings.ReplaceAll(query, "$", d.Placeholder)
+}
+
+// timestamp scans a timestamp stored in either form Dialect.Time gives.
+// NULL scans into the zero time.
+type timestamp struct {
+	t *time.Time
+}
+
+func scanTime(t *time.Time) timestamp {
+	return timestamp{t: t}
+}
+
+func (ts timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case time.Time:
+		*ts.t = v
+	case int64:
+		*ts.t = time.Unix(0, v)
+	case nil:
+		*ts.t = time.Time{}
+	default:
+		return fmt.Errorf("can't scan %T into timestamp", src)
+	}
+
+	return nil
+}
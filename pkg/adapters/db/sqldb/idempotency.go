@@ -0,0 +1,123 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// ClaimIdempotencyKey deletes an expired record of the key first, so the
+// key can be claimed again once its record expires. Other expired records
+// are left to PurgeIdempotencyKeys.
+func (db *DB) ClaimIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var stored *domain.IdempotencyRecord
+
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.StmtContext(ctx, db.stmts.purgeKey).ExecContext(ctx, rec.Key, db.dialect.Time(time.Now())); err != nil {
+			return err
+		}
+
+		res, err := tx.StmtContext(ctx, db.stmts.claimKey).ExecContext(ctx, rec.Key, rec.Fingerprint, db.dialect.Time(rec.ExpiresAt))
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+
+		stored, err = scanIdempotencyRecord(tx.StmtContext(ctx, db.stmts.getKey).QueryRowContext(ctx, rec.Key))
+		if err != nil {
+			return err
+		}
+
+		stored.Key = rec.Key
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func (db *DB) CompleteIdempotencyKey(ctx context.Context, rec *domain.IdempotencyRecord) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", rec.Key)
+
+	if rec.Response == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	_, err := db.stmts.completeKey.ExecContext(ctx, rec.Key, rec.Fingerprint,
+		rec.Response.Status, rec.Response.ContentType, rec.Response.Body, db.dialect.Time(rec.ExpiresAt))
+
+	return err
+}
+
+func (db *DB) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", key)
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	_, err := db.stmts.releaseKey.ExecContext(ctx, key)
+
+	return err
+}
+
+func scanIdempotencyRecord(row scanner) (*domain.IdempotencyRecord, error) {
+	var (
+		rec         domain.IdempotencyRecord
+		status      sql.NullInt64
+		contentType sql.NullString
+		body        []byte
+	)
+
+	if err := row.Scan(&rec.Fingerprint, &status, &contentType, &body, scanTime(&rec.ExpiresAt)); err != nil {
+		return nil, err
+	}
+
+	if status.Valid {
+		rec.Response = &domain.IdempotentResponse{
+			Status:      int(status.Int64),
+			ContentType: contentType.String,
+			Body:        body,
+		}
+	}
+
+	return &rec, nil
+}
+
+func (db *DB) PurgeIdempotencyKeys(ctx context.Context) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	_, err := db.stmts.purgeKeys.ExecContext(ctx, db.dialect.Time(time.Now()))
+
+	return err
+}
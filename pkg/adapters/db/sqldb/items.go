@@ -0,0 +1,375 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItem(row scanner) (*domain.Item, error) {
+	var (
+		it       domain.Item
+		currency string
+		quantity int64
+	)
+
+	err := row.Scan(&it.ID, &it.OwnerID, &it.Name, &it.Description, &it.Category,
+		&it.Price.Amount, &currency, scanTime(&it.CreatedAt), &quantity)
+	if err != nil {
+		return nil, err
+	}
+
+	it.Price.Currency = currency
+	it.Quantity = uint64(quantity)
+
+	return &it, nil
+}
+
+func (db *DB) AddItem(ctx context.Context, item *domain.AddItemRequest) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if item == nil {
+		return "", domain.ErrNoItem
+	}
+
+	if !db.validID(item.OwnerID) {
+		return "", domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	price := domain.NewMoney(item.Price.Amount, item.Price.Currency)
+
+	id := db.dialect.NewID()
+	_, err := db.stmts.insertItem.ExecContext(ctx, id, item.OwnerID, item.Name, item.Description, item.Category,
+		price.Amount, price.Currency, db.dialect.Time(time.Now()), int64(item.Quantity))
+	if err != nil {
+		return "", err
+	}
+
+	span.SetTag("result_id", id)
+
+	return id, nil
+}
+
+func (db *DB) GetItemById(ctx context.Context, id string) (*domain.Item, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("item_id", id)
+
+	if !db.validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	it, err := scanItem(db.stmts.getItem.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrItemNotFound
+		}
+
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (db *DB) FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	q := db.list()
+	if err := itemConditions(q, &filter); err != nil {
+		return nil, err
+	}
+
+	key := "created_at"
+	if page.Sort.ByPrice() {
+		key = "price_amount"
+	}
+
+	query, err := q.page(itemColumns, "items", key, page)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := db.findItems(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewItemsPage(items, page), nil
+}
+
+func (db *DB) CountItemFacets(ctx context.Context, filter domain.ItemFilter, buckets domain.PriceBuckets) (*domain.ItemFacets, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("currency", buckets.Currency)
+
+	categoryQuery, priceQuery := db.list(), db.list()
+
+	categoryFilter := filter.CategoryFacetFilter()
+	if err := itemConditions(categoryQuery, &categoryFilter); err != nil {
+		return nil, err
+	}
+
+	priceFilter := filter.PriceFacetFilter(buckets)
+	if err := itemConditions(priceQuery, &priceFilter); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	categories, err := countBy[string](ctx, db.conn,
+		categoryQuery.selectFrom("category, COUNT(*)", "items")+" GROUP BY category", categoryQuery.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketCounts, err := countBy[int64](ctx, db.conn,
+		priceQuery.selectFrom(priceBucketExpr(buckets)+", COUNT(*)", "items")+" GROUP BY 1", priceQuery.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make([]int64, len(buckets.Bounds))
+	for i, count := range bucketCounts {
+		if i >= 0 && i < int64(len(prices)) {
+			prices[i] = count
+		}
+	}
+
+	return domain.NewItemFacets(categories, prices, buckets), nil
+}
+
+// priceBucketExpr returns an expression giving the index of the price
+// bucket of an item, or -1 if the price is below all buckets. Bounds
+// are numbers, so they are inlined into the query.
+func priceBucketExpr(buckets domain.PriceBuckets) string {
+	var b strings.Builder
+	b.WriteString("CASE")
+	for i := len(buckets.Bounds) - 1; i >= 0; i-- {
+		b.WriteString(" WHEN price_amount >= " + strconv.FormatInt(buckets.Bounds[i], 10) + " THEN " + strconv.Itoa(i))
+	}
+	b.WriteString(" ELSE -1 END")
+
+	return b.String()
+}
+
+// countBy runs a query returning keys with their counts.
+func countBy[K comparable](ctx context.Context, conn *sql.DB, query string, args ...interface{}) (map[K]int64, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[K]int64)
+	for rows.Next() {
+		var (
+			key   K
+			count int64
+		)
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+
+		counts[key] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// SearchItems ranks matching items in process. Where the dialect has a
+// case insensitive LIKE, items are narrowed down to ones containing any
+// query term first. Terms have only letters and digits, so they need no
+// escaping in patterns.
+func (db *DB) SearchItems(ctx context.Context, req domain.SearchRequest) ([]*domain.SearchHit, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("query", req.Query)
+	span.SetTag("limit", req.Limit)
+
+	q := db.list()
+
+	if like := db.dialect.ILike; like != "" {
+		var (
+			conds []string
+			args  []interface{}
+		)
+		for _, term := range domain.SearchTerms(req.Query) {
+			pattern := "%" + term + "%"
+			conds = append(conds, "name "+like+" ? OR description "+like+" ? OR category "+like+" ?")
+			args = append(args, pattern, pattern, pattern)
+		}
+
+		q.where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+
+	return db.searchItems(ctx, req, q.selectFrom(itemColumns, "items"), q.args...)
+}
+
+func (db *DB) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if !db.validID(id) {
+		return 0, domain.ErrInvalidId
+	}
+
+	if in == nil || *in == (domain.UpdateItemRequest{}) {
+		return 0, domain.ErrNoUpdate
+	}
+
+	if in.OwnerID != nil && !db.validID(*in.OwnerID) {
+		return 0, domain.ErrInvalidId
+	}
+
+	var (
+		priceAmount   *int64
+		priceCurrency *string
+		quantity      *int64
+	)
+	if in.Price != nil {
+		price := domain.NewMoney(in.Price.Amount, in.Price.Currency)
+		priceAmount, priceCurrency = &price.Amount, &price.Currency
+	}
+	if in.Quantity != nil {
+		q := int64(*in.Quantity)
+		quantity = &q
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	res, err := db.stmts.updateItem.ExecContext(ctx, id, in.Name, in.Description, in.Category,
+		in.OwnerID, priceAmount, priceCurrency, quantity)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if count == 0 {
+		return 0, domain.ErrItemNotFound
+	}
+
+	return count, nil
+}
+
+func (db *DB) findItems(ctx context.Context, query string, args ...interface{}) ([]*domain.Item, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*domain.Item, 0)
+	for rows.Next() {
+		it, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, it)
+	}
+
+	return result, rows.Err()
+}
+
+// searchItems matches items returned by the query in process.
+func (db *DB) searchItems(ctx context.Context, req domain.SearchRequest, query string, args ...interface{}) ([]*domain.SearchHit, error) {
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matcher := domain.NewItemMatcher(req.Query)
+
+	hits := make([]*domain.SearchHit, 0)
+	for rows.Next() {
+		it, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if hit, ok := matcher.Match(it); ok {
+			hits = append(hits, hit)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return domain.RankHits(hits, req.Limit), nil
+}
+
+// itemConditions adds conditions selecting items that match the filter.
+func itemConditions(q *listQuery, filter *domain.ItemFilter) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	if filter.OwnerID != "" {
+		if !q.dialect.ValidID(filter.OwnerID) {
+			return domain.ErrInvalidId
+		}
+		q.where("owner_id = ?", filter.OwnerID)
+	}
+	if len(filter.Categories) > 0 {
+		q.whereIn("category", filter.Categories)
+	}
+	if currency := filter.PriceCurrency(); currency != "" {
+		q.where("price_currency = ?", currency)
+	}
+	if filter.MinPrice != nil {
+		q.where("price_amount >= ?", filter.MinPrice.Amount)
+	}
+	if filter.MaxPrice != nil {
+		q.where("price_amount <= ?", filter.MaxPrice.Amount)
+	}
+	if filter.InStock {
+		q.where("quantity > 0")
+	}
+	if !filter.CreatedAfter.IsZero() {
+		q.where("created_at >= ?", q.dialect.Time(filter.CreatedAfter))
+	}
+
+	return nil
+}
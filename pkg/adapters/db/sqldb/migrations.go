@@ -0,0 +1,111 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrate applies migrations that aren't recorded in schema_migrations
+// yet. Each migration runs in its own transaction.
+func migrate(ctx context.Context, db *sql.DB, d *Dialect) error {
+	migrations, err := loadMigrations(d.Migrations)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, d.MigrationsTable); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if err := applyMigration(ctx, db, d, m); err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, d *Dialect, m migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if d.LockMigrations != "" {
+		if _, err := tx.ExecContext(ctx, d.LockMigrations); err != nil {
+			return err
+		}
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, d.rebind("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)"), m.version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, d.rebind("INSERT INTO schema_migrations (version) VALUES ($1)"), m.version); err != nil {
+		return err
+	}
+
+	log.Info().Str("migration", m.name).Msgf("Applied %s migration", d.Name)
+
+	return tx.Commit()
+}
+
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, e := range entries {
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version prefix", e.Name())
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, migration{
+			version: version,
+			name:    e.Name(),
+			sql:     string(data),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+
+	return migrations, nil
+}
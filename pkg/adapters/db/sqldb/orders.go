@@ -0,0 +1,644 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func scanOrder(row scanner) (*domain.Order, error) {
+	var ord domain.Order
+
+	err := row.Scan(&ord.ID, &ord.CustomerID, &ord.Total.Amount, &ord.Total.Currency, scanTime(&ord.CreatedAt), &ord.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	ord.Items = []domain.OrderItem{}
+	ord.Transitions = []domain.StatusTransition{}
+
+	return &ord, nil
+}
+
+func (db *DB) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if req == nil {
+		return "", domain.ErrNoOrder
+	}
+
+	if !db.validID(req.CustomerID) {
+		return "", domain.ErrInvalidId
+	}
+
+	for _, it := range req.Items {
+		if !db.validID(it.ID) {
+			return "", domain.ErrInvalidId
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	id := db.dialect.NewID()
+
+	// Stock reservation, pricing, order insertion and clearing of the
+	// cart ordered from either all happen or none of them do.
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		if req.CartVersion != 0 {
+			if err := db.checkoutCart(ctx, tx, req.CustomerID, req.CartVersion); err != nil {
+				return err
+			}
+		}
+
+		lines, total, err := db.reserveStock(ctx, tx, req.Items)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.StmtContext(ctx, db.stmts.insertOrder).ExecContext(ctx, id, req.CustomerID,
+			total.Amount, total.Currency, db.dialect.Time(time.Now()), domain.CREATED)
+		if err != nil {
+			return err
+		}
+
+		insertLine := tx.StmtContext(ctx, db.stmts.insertLine)
+		for i, line := range lines {
+			_, err := insertLine.ExecContext(ctx, id, i, line.ID, line.Quantity,
+				line.Price.Amount, line.Price.Currency, nullID(line.SellerID))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	span.SetTag("result_id", id)
+
+	return id, nil
+}
+
+// reserveStock decrements the quantity of every ordered item and records
+// its current price and seller. Items that don't have enough stock are
+// collected into ErrInsufficientStock. All items of an order must be
+// priced in the same currency.
+func (db *DB) reserveStock(ctx context.Context, tx *sql.Tx, items []domain.OrderItem) ([]domain.OrderItem, domain.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var (
+		short []string
+		total domain.Money
+	)
+
+	reserve := tx.StmtContext(ctx, db.stmts.reserveItem)
+	lines := make([]domain.OrderItem, 0, len(items))
+
+	for _, it := range items {
+		line := domain.OrderItem{
+			ID:       it.ID,
+			Quantity: it.Quantity,
+		}
+
+		err := reserve.QueryRowContext(ctx, it.ID, it.Quantity).Scan(&line.Price.Amount, &line.Price.Currency, &line.SellerID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				short = append(short, it.ID)
+				continue
+			}
+
+			return nil, domain.Money{}, err
+		}
+
+		subtotal, err := line.Price.Mul(line.Quantity)
+		if err != nil {
+			return nil, domain.Money{}, err
+		}
+
+		total, err = total.Add(subtotal)
+		if err != nil {
+			return nil, domain.Money{}, err
+		}
+
+		lines = append(lines, line)
+	}
+
+	if len(short) > 0 {
+		span.SetTag("short_items", short)
+		return nil, domain.Money{}, domain.NewInsufficientStockError(short)
+	}
+
+	return lines, total, nil
+}
+
+// releaseStock returns the reserved quantity of every ordered item.
+func (db *DB) releaseStock(ctx context.Context, tx *sql.Tx, items []domain.OrderItem) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	release := tx.StmtContext(ctx, db.stmts.releaseItem)
+	for _, it := range items {
+		if _, err := release.ExecContext(ctx, it.ID, it.Quantity); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetOrderInfo(ctx context.Context, id string) (*domain.Order, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if !db.validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	ord, err := scanOrder(db.stmts.getOrder.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrOrderNotFound
+		}
+
+		return nil, err
+	}
+
+	if err := db.loadOrderDetails(ctx, nil, ord); err != nil {
+		return nil, err
+	}
+
+	return ord, nil
+}
+
+func (db *DB) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("customer_id", id)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if !db.validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	q := db.list()
+	q.where("customer_id = ?", id)
+
+	query, err := q.page(orderColumns, "orders", "created_at", page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*domain.Order, 0, page.Limit+1)
+	for rows.Next() {
+		ord, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, ord)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadOrderDetails(ctx, nil, result...); err != nil {
+		return nil, err
+	}
+
+	return domain.NewOrdersPage(result, page), nil
+}
+
+func (db *DB) GetUnpaidOrderIds(ctx context.Context, createdBefore time.Time, limit int64) ([]string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("created_before", createdBefore)
+	span.SetTag("limit", limit)
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.stmts.unpaidOrders.QueryContext(ctx, domain.CREATED, db.dialect.Time(createdBefore), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (db *DB) UpdateOrder(ctx context.Context, id string, ord domain.UpdateOrderRequest) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if ord.Items == nil && ord.Status == nil {
+		return domain.ErrNoUpdate
+	}
+
+	if !db.validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	if ord.Items != nil {
+		for _, it := range *ord.Items {
+			if !db.validID(it.ID) {
+				return domain.ErrInvalidId
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := scanOrder(tx.StmtContext(ctx, db.stmts.lockOrder).QueryRowContext(ctx, id)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrOrderNotFound
+			}
+
+			return err
+		}
+
+		if ord.Status != nil {
+			if _, err := tx.StmtContext(ctx, db.stmts.setOrderStatus).ExecContext(ctx, id, *ord.Status); err != nil {
+				return err
+			}
+		}
+
+		if ord.Items == nil {
+			return nil
+		}
+
+		if _, err := tx.StmtContext(ctx, db.stmts.deleteLines).ExecContext(ctx, id); err != nil {
+			return err
+		}
+
+		insertLine := tx.StmtContext(ctx, db.stmts.insertLine)
+		for i, line := range *ord.Items {
+			_, err := insertLine.ExecContext(ctx, id, i, line.ID, line.Quantity,
+				line.Price.Amount, line.Price.Currency, nullID(line.SellerID))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (db *DB) TransitionOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+	span.SetTag("from", string(tr.From))
+	span.SetTag("to", string(tr.To))
+
+	if !db.validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	// The status is changed first, so the stock of an order is returned
+	// only once however many transitions race.
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		if err := db.transitOrder(ctx, tx, id, tr); err != nil {
+			return err
+		}
+
+		if !tr.ReleasesStock() {
+			return nil
+		}
+
+		order, err := scanOrder(tx.StmtContext(ctx, db.stmts.getOrder).QueryRowContext(ctx, id))
+		if err != nil {
+			return err
+		}
+
+		if err := db.loadOrderDetails(ctx, tx, order); err != nil {
+			return err
+		}
+
+		return db.releaseStock(ctx, tx, order.Items)
+	})
+}
+
+func (db *DB) PayOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	return db.settleOrder(ctx, id, tr, db.chargeOrder)
+}
+
+func (db *DB) RefundOrder(ctx context.Context, id string, tr domain.StatusTransition) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", id)
+
+	return db.settleOrder(ctx, id, tr, db.refundOrder)
+}
+
+// settleOrder runs the money movement for the order and applies the
+// transition in one transaction. The order row is read for update, so
+// concurrent settlements are serialized.
+func (db *DB) settleOrder(
+	ctx context.Context,
+	id string,
+	tr domain.StatusTransition,
+	move func(ctx context.Context, tx *sql.Tx, order *domain.Order) error,
+) error {
+	if !db.validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	return db.inTx(ctx, func(tx *sql.Tx) error {
+		order, err := scanOrder(tx.StmtContext(ctx, db.stmts.lockOrder).QueryRowContext(ctx, id))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrOrderNotFound
+			}
+
+			return err
+		}
+
+		if order.Status != tr.From {
+			return domain.ErrOrderStatusConflict
+		}
+
+		if err := db.loadOrderDetails(ctx, tx, order); err != nil {
+			return err
+		}
+
+		if err := move(ctx, tx, order); err != nil {
+			return err
+		}
+
+		return db.transitOrder(ctx, tx, id, tr)
+	})
+}
+
+// chargeOrder debits the order total from the customer and pays every
+// seller for their lines.
+func (db *DB) chargeOrder(ctx context.Context, tx *sql.Tx, order *domain.Order) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	customer, err := scanUser(tx.StmtContext(ctx, db.stmts.lockUser).QueryRowContext(ctx, order.CustomerID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrUserNotFound
+		}
+
+		return err
+	}
+
+	short, err := customer.Balance.Less(order.Total)
+	if err != nil {
+		return err
+	}
+
+	if short {
+		return domain.ErrInsufficientFunds
+	}
+
+	_, err = db.postTransaction(ctx, tx, newOrderTransaction(order.CustomerID, order.ID, domain.PAYMENT, order.Total.Neg()))
+	if err != nil {
+		return err
+	}
+
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	for _, sellerID := range sortedKeys(shares) {
+		_, err := db.postTransaction(ctx, tx, newOrderTransaction(sellerID, order.ID, domain.PAYOUT, shares[sellerID]))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refundOrder returns the order total to the customer and takes the
+// payouts back from the sellers.
+func (db *DB) refundOrder(ctx context.Context, tx *sql.Tx, order *domain.Order) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	_, err := db.postTransaction(ctx, tx, newOrderTransaction(order.CustomerID, order.ID, domain.REFUND, order.Total))
+	if err != nil {
+		return err
+	}
+
+	shares, err := sellerShares(order.Items)
+	if err != nil {
+		return err
+	}
+
+	for _, sellerID := range sortedKeys(shares) {
+		_, err := db.postTransaction(ctx, tx, newOrderTransaction(sellerID, order.ID, domain.REFUND, shares[sellerID].Neg()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transitOrder moves the order to tr.To only if it's still in tr.From
+// and records the transition in the order history.
+func (db *DB) transitOrder(ctx context.Context, tx *sql.Tx, id string, tr domain.StatusTransition) error {
+	res, err := tx.StmtContext(ctx, db.stmts.transitOrder).ExecContext(ctx, id, tr.From, tr.To)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		found, err := exists(ctx, tx.StmtContext(ctx, db.stmts.orderExists), id)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return domain.ErrOrderNotFound
+		}
+
+		return domain.ErrOrderStatusConflict
+	}
+
+	_, err = tx.StmtContext(ctx, db.stmts.insertHistory).ExecContext(ctx, id, tr.From, tr.To, db.dialect.Time(tr.At), tr.Actor)
+
+	return err
+}
+
+// loadOrderDetails fills lines and status history of the given orders.
+// tx may be nil when the orders are read outside of a transaction.
+func (db *DB) loadOrderDetails(ctx context.Context, tx *sql.Tx, orders ...*domain.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	var conn querier = db.conn
+	if tx != nil {
+		conn = tx
+	}
+
+	ids := make([]string, len(orders))
+	byID := make(map[string]*domain.Order, len(orders))
+	for i, ord := range orders {
+		ids[i] = ord.ID
+		byID[ord.ID] = ord
+	}
+
+	lines := db.list()
+	lines.whereIn("order_id", ids)
+
+	rows, err := conn.QueryContext(ctx, lines.selectFrom("order_id, item_id, quantity, price_amount, price_currency, seller_id",
+		"order_items")+" ORDER BY order_id, position", lines.args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID  string
+			line     domain.OrderItem
+			sellerID sql.NullString
+		)
+
+		err := rows.Scan(&orderID, &line.ID, &line.Quantity, &line.Price.Amount, &line.Price.Currency, &sellerID)
+		if err != nil {
+			return err
+		}
+
+		line.SellerID = sellerID.String
+		byID[orderID].Items = append(byID[orderID].Items, line)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	transitions := db.list()
+	transitions.whereIn("order_id", ids)
+
+	history, err := conn.QueryContext(ctx, transitions.selectFrom("order_id, from_status, to_status, at, actor",
+		"order_transitions")+" ORDER BY id", transitions.args...)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	for history.Next() {
+		var (
+			orderID string
+			tr      domain.StatusTransition
+		)
+
+		if err := history.Scan(&orderID, &tr.From, &tr.To, scanTime(&tr.At), &tr.Actor); err != nil {
+			return err
+		}
+
+		byID[orderID].Transitions = append(byID[orderID].Transitions, tr)
+	}
+
+	return history.Err()
+}
+
+func sellerShares(items []domain.OrderItem) (map[string]domain.Money, error) {
+	shares := map[string]domain.Money{}
+	for _, item := range items {
+		if item.SellerID == "" {
+			continue
+		}
+
+		share, err := item.Price.Mul(item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		if prev, ok := shares[item.SellerID]; ok {
+			// Lines were priced in one currency when the order was placed.
+			share, err = prev.Add(share)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		shares[item.SellerID] = share
+	}
+
+	return shares, nil
+}
+
+// sortedKeys returns user IDs in a stable order, so concurrent
+// settlements lock user rows in the same sequence.
+func sortedKeys(shares map[string]domain.Money) []string {
+	keys := make([]string, 0, len(shares))
+	for k := range shares {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func newOrderTransaction(userID, orderID string, kind domain.TransactionKind, amount domain.Money) *domain.PostTransactionRequest {
+	return &domain.PostTransactionRequest{
+		UserID:  userID,
+		OrderID: orderID,
+		Kind:    kind,
+		Amount:  amount,
+	}
+}
@@ -0,0 +1,90 @@
+package sqldb
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// listQuery builds a SELECT returning a page of rows. Lists can be
+// filtered in many ways, so unlike other queries of the adapter they
+// aren't prepared.
+type listQuery struct {
+	dialect *Dialect
+	conds   []string
+	args    []interface{}
+}
+
+// where adds a condition. Every "?" in cond is replaced by a placeholder
+// of the next argument.
+func (q *listQuery) where(cond string, args ...interface{}) {
+	parts := strings.Split(cond, "?")
+
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for i, arg := range args {
+		b.WriteString(q.arg(arg))
+		b.WriteString(parts[i+1])
+	}
+
+	q.conds = append(q.conds, b.String())
+}
+
+// whereIn adds a condition matching rows whose column holds any of the
+// values.
+func (q *listQuery) whereIn(column string, values []string) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+
+	q.where(column+" IN (?"+strings.Repeat(", ?", len(args)-1)+")", args...)
+}
+
+// arg adds an argument and returns its placeholder.
+func (q *listQuery) arg(arg interface{}) string {
+	q.args = append(q.args, arg)
+	return q.dialect.Placeholder + strconv.Itoa(len(q.args))
+}
+
+// page returns the query selecting rows of the table that follow the
+// page cursor, sorted by the key column and then by ID. One row more
+// than the page limit is fetched to tell if there is a next page.
+func (q *listQuery) page(columns, table, key string, page domain.PageRequest) (string, error) {
+	dir, op := "ASC", ">"
+	if page.Sort.Descending() {
+		dir, op = "DESC", "<"
+	}
+
+	if page.After != nil {
+		if !q.dialect.ValidID(page.After.ID) {
+			return "", domain.ErrInvalidCursor
+		}
+
+		// Cursor keys of timestamps are Unix nanoseconds.
+		var after interface{} = page.After.Key
+		if key == "created_at" {
+			after = q.dialect.Time(time.Unix(0, page.After.Key))
+		}
+
+		q.where("("+key+", id) "+op+" (?, ?)", after, page.After.ID)
+	}
+
+	query := q.selectFrom(columns, table)
+	query += " ORDER BY " + key + " " + dir + ", id " + dir + " LIMIT " + q.arg(page.Limit+1)
+
+	return query, nil
+}
+
+// selectFrom returns the query selecting rows of the table that match
+// all conditions.
+func (q *listQuery) selectFrom(columns, table string) string {
+	query := "SELECT " + columns + " FROM " + table
+	if len(q.conds) > 0 {
+		query += " WHERE " + strings.Join(q.conds, " AND ")
+	}
+
+	return query
+}
@@ -0,0 +1,151 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+)
+
+const (
+	itemColumns  = "id, owner_id, name, description, category, price_amount, price_currency, created_at, quantity"
+	userColumns  = "id, name, email, phone, created_at, balance_amount, balance_currency"
+	orderColumns = "id, customer_id, total_amount, total_currency, created_at, status"
+	txColumns    = "id, user_id, order_id, kind, amount, currency, created_at"
+)
+
+type statements struct {
+	insertItem     *sql.Stmt
+	getItem        *sql.Stmt
+	updateItem     *sql.Stmt
+	reserveItem    *sql.Stmt
+	releaseItem    *sql.Stmt
+	insertUser     *sql.Stmt
+	getUser        *sql.Stmt
+	userPassword   *sql.Stmt
+	lockUser       *sql.Stmt
+	userExists     *sql.Stmt
+	userRoles      *sql.Stmt
+	addRole        *sql.Stmt
+	removeRole     *sql.Stmt
+	changeBalance  *sql.Stmt
+	setBalance     *sql.Stmt
+	insertOrder    *sql.Stmt
+	insertLine     *sql.Stmt
+	getOrder       *sql.Stmt
+	lockOrder      *sql.Stmt
+	orderExists    *sql.Stmt
+	unpaidOrders   *sql.Stmt
+	deleteLines    *sql.Stmt
+	setOrderStatus *sql.Stmt
+	transitOrder   *sql.Stmt
+	insertHistory  *sql.Stmt
+	insertTx       *sql.Stmt
+	sumTxs         *sql.Stmt
+	getCart        *sql.Stmt
+	touchCart      *sql.Stmt
+	cartVersion    *sql.Stmt
+	checkoutCart   *sql.Stmt
+	setCartItem    *sql.Stmt
+	removeCartItem *sql.Stmt
+	clearCart      *sql.Stmt
+	purgeKeys      *sql.Stmt
+	purgeKey       *sql.Stmt
+	claimKey       *sql.Stmt
+	getKey         *sql.Stmt
+	completeKey    *sql.Stmt
+	releaseKey     *sql.Stmt
+
+	all []*sql.Stmt
+}
+
+func prepareStatements(ctx context.Context, conn *sql.DB, d *Dialect) (*statements, error) {
+	s := new(statements)
+
+	queries := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.insertItem, `INSERT INTO items (` + itemColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`},
+		{&s.getItem, `SELECT ` + itemColumns + ` FROM items WHERE id = $1`},
+		{&s.updateItem, `UPDATE items SET
+				name = COALESCE($2, name),
+				description = COALESCE($3, description),
+				category = COALESCE($4, category),
+				owner_id = COALESCE($5, owner_id),
+				price_amount = COALESCE($6, price_amount),
+				price_currency = COALESCE($7, price_currency),
+				quantity = COALESCE($8, quantity)
+			WHERE id = $1`},
+		{&s.reserveItem, `UPDATE items SET quantity = quantity - $2
+			WHERE id = $1 AND quantity >= $2 RETURNING price_amount, price_currency, owner_id`},
+		{&s.releaseItem, `UPDATE items SET quantity = quantity + $2 WHERE id = $1`},
+		{&s.insertUser, `INSERT INTO users (` + userColumns + `, password_hash) VALUES ($1, $2, $3, $4, $5, 0, $6, $7)`},
+		{&s.getUser, `SELECT ` + userColumns + ` FROM users WHERE id = $1`},
+		{&s.userPassword, `SELECT id, password_hash FROM users WHERE email = $1`},
+		{&s.lockUser, `SELECT ` + userColumns + ` FROM users WHERE id = $1` + d.ForUpdate},
+		{&s.userExists, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`},
+		{&s.userRoles, `SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role`},
+		{&s.addRole, `INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING`},
+		{&s.removeRole, `DELETE FROM user_roles WHERE user_id = $1 AND role = $2`},
+		{&s.changeBalance, `UPDATE users SET balance_amount = balance_amount + $2
+			WHERE id = $1 AND balance_currency = $3`},
+		{&s.setBalance, `UPDATE users SET balance_amount = $2 WHERE id = $1`},
+		{&s.insertOrder, `INSERT INTO orders (` + orderColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`},
+		{&s.insertLine, `INSERT INTO order_items (order_id, position, item_id, quantity, price_amount, price_currency, seller_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`},
+		{&s.getOrder, `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`},
+		{&s.lockOrder, `SELECT ` + orderColumns + ` FROM orders WHERE id = $1` + d.ForUpdate},
+		{&s.orderExists, `SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)`},
+		{&s.unpaidOrders, `SELECT id FROM orders WHERE status = $1 AND created_at < $2 ORDER BY created_at LIMIT $3`},
+		{&s.deleteLines, `DELETE FROM order_items WHERE order_id = $1`},
+		{&s.setOrderStatus, `UPDATE orders SET status = $2 WHERE id = $1`},
+		{&s.transitOrder, `UPDATE orders SET status = $3 WHERE id = $1 AND status = $2`},
+		{&s.insertHistory, `INSERT INTO order_transitions (order_id, from_status, to_status, at, actor)
+			VALUES ($1, $2, $3, $4, $5)`},
+		{&s.insertTx, `INSERT INTO transactions (` + txColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7)`},
+		{&s.sumTxs, `SELECT COALESCE(SUM(amount), 0) FROM transactions WHERE user_id = $1`},
+		{&s.getCart, `SELECT c.version, c.updated_at, i.item_id, i.quantity, i.added_at
+			FROM carts c LEFT JOIN cart_items i ON i.user_id = c.user_id
+			WHERE c.user_id = $1 ORDER BY i.added_at, i.item_id`},
+		{&s.touchCart, `INSERT INTO carts (user_id, version, updated_at) VALUES ($1, 1, $2)
+			ON CONFLICT (user_id) DO UPDATE SET version = carts.version + 1, updated_at = EXCLUDED.updated_at`},
+		{&s.cartVersion, `SELECT version FROM carts WHERE user_id = $1`},
+		{&s.checkoutCart, `UPDATE carts SET version = version + 1, updated_at = $3 WHERE user_id = $1 AND version = $2`},
+		{&s.setCartItem, `INSERT INTO cart_items (user_id, item_id, quantity, added_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id, item_id) DO UPDATE SET quantity = EXCLUDED.quantity`},
+		{&s.removeCartItem, `DELETE FROM cart_items WHERE user_id = $1 AND item_id = $2`},
+		{&s.clearCart, `DELETE FROM cart_items WHERE user_id = $1`},
+		{&s.purgeKeys, `DELETE FROM idempotency_keys WHERE expires_at <= $1`},
+		{&s.purgeKey, `DELETE FROM idempotency_keys WHERE key = $1 AND expires_at <= $2`},
+		{&s.claimKey, `INSERT INTO idempotency_keys (key, fingerprint, expires_at) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO NOTHING`},
+		{&s.getKey, `SELECT fingerprint, status, content_type, body, expires_at FROM idempotency_keys WHERE key = $1`},
+		{&s.completeKey, `UPDATE idempotency_keys SET status = $3, content_type = $4, body = $5, expires_at = $6
+			WHERE key = $1 AND fingerprint = $2`},
+		{&s.releaseKey, `DELETE FROM idempotency_keys WHERE key = $1`},
+	}
+
+	for _, q := range queries {
+		stmt, err := conn.PrepareContext(ctx, d.rebind(q.query))
+		if err != nil {
+			_ = s.close()
+			return nil, err
+		}
+
+		*q.stmt = stmt
+		s.all = append(s.all, stmt)
+	}
+
+	return s, nil
+}
+
+func (s *statements) close() error {
+	var firstErr error
+
+	for _, stmt := range s.all {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
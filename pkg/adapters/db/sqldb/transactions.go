@@ -0,0 +1,243 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func scanTransaction(row scanner) (*domain.Transaction, error) {
+	var (
+		tx      domain.Transaction
+		orderID sql.NullString
+	)
+
+	err := row.Scan(&tx.ID, &tx.UserID, &orderID, &tx.Kind, &tx.Amount.Amount, &tx.Amount.Currency, scanTime(&tx.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	tx.OrderID = orderID.String
+
+	return &tx, nil
+}
+
+func (db *DB) PostTransaction(ctx context.Context, req *domain.PostTransactionRequest) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", req.UserID)
+	span.SetTag("kind", string(req.Kind))
+	span.SetTag("amount", req.Amount.String())
+
+	if !db.validID(req.UserID) || (req.OrderID != "" && !db.validID(req.OrderID)) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var result *domain.Transaction
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		result, err = db.postTransaction(ctx, tx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", result.ID)
+
+	return result, nil
+}
+
+func (db *DB) Withdraw(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("amount", amount.String())
+
+	if !db.validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var result *domain.Transaction
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		user, err := scanUser(tx.StmtContext(ctx, db.stmts.lockUser).QueryRowContext(ctx, userID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrUserNotFound
+			}
+
+			return err
+		}
+
+		short, err := user.Balance.Less(amount)
+		if err != nil {
+			return err
+		}
+
+		if short {
+			return domain.ErrInsufficientFunds
+		}
+
+		result, err = db.postTransaction(ctx, tx, &domain.PostTransactionRequest{
+			UserID: userID,
+			Kind:   domain.WITHDRAWAL,
+			Amount: amount.Neg(),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetTag("result_id", result.ID)
+
+	return result, nil
+}
+
+func (db *DB) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if !db.validID(userID) {
+		return nil, domain.ErrInvalidId
+	}
+
+	q := db.list()
+	q.where("user_id = ?", userID)
+
+	query, err := q.page(txColumns, "transactions", "created_at", page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*domain.Transaction, 0, page.Limit+1)
+	for rows.Next() {
+		tx, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, tx)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return domain.NewTransactionsPage(result, page), nil
+}
+
+func (db *DB) RecomputeBalance(ctx context.Context, userID string) (domain.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if !db.validID(userID) {
+		return domain.Money{}, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var balance domain.Money
+	err := db.inTx(ctx, func(tx *sql.Tx) error {
+		user, err := scanUser(tx.StmtContext(ctx, db.stmts.lockUser).QueryRowContext(ctx, userID))
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return domain.ErrUserNotFound
+			}
+
+			return err
+		}
+
+		balance.Currency = user.Balance.Currency
+		if err := tx.StmtContext(ctx, db.stmts.sumTxs).QueryRowContext(ctx, userID).Scan(&balance.Amount); err != nil {
+			return err
+		}
+
+		_, err = tx.StmtContext(ctx, db.stmts.setBalance).ExecContext(ctx, userID, balance.Amount)
+
+		return err
+	})
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	span.SetTag("balance", balance.String())
+
+	return balance, nil
+}
+
+// postTransaction appends the entry to the ledger and applies it to the
+// user's balance. It must be called inside a transaction.
+func (db *DB) postTransaction(ctx context.Context, tx *sql.Tx, req *domain.PostTransactionRequest) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	user, err := scanUser(tx.StmtContext(ctx, db.stmts.lockUser).QueryRowContext(ctx, req.UserID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	if user.Balance.Currency != req.Amount.Currency {
+		return nil, domain.ErrCurrencyMismatch
+	}
+
+	// The user row is locked, so the balance can't change meanwhile.
+	if _, err := user.Balance.Add(req.Amount); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.StmtContext(ctx, db.stmts.changeBalance).ExecContext(ctx, req.UserID, req.Amount.Amount, req.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.Transaction{
+		ID:        db.dialect.NewID(),
+		UserID:    req.UserID,
+		OrderID:   req.OrderID,
+		Kind:      req.Kind,
+		Amount:    req.Amount,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = tx.StmtContext(ctx, db.stmts.insertTx).ExecContext(ctx, result.ID, req.UserID, nullID(req.OrderID),
+		req.Kind, req.Amount.Amount, req.Amount.Currency, db.dialect.Time(result.CreatedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
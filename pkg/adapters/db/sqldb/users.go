@@ -0,0 +1,216 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func scanUser(row scanner) (*domain.User, error) {
+	var user domain.User
+
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Phone, scanTime(&user.CreatedAt),
+		&user.Balance.Amount, &user.Balance.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Roles = []domain.Role{}
+
+	return &user, nil
+}
+
+func (db *DB) RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	id := db.dialect.NewID()
+	_, err := db.stmts.insertUser.ExecContext(ctx, id, user.Name, user.Email, user.Phone,
+		db.dialect.Time(time.Now()), domain.DefaultCurrency, user.PasswordHash)
+	if err != nil {
+		if db.dialect.UniqueViolation(err) {
+			return "", domain.ErrEmailTaken
+		}
+
+		return "", err
+	}
+
+	span.SetTag("result_id", id)
+
+	return id, nil
+}
+
+func (db *DB) GetUserById(ctx context.Context, id string) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+
+	if !db.validID(id) {
+		return nil, domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	user, err := scanUser(db.stmts.getUser.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	if err := db.loadUserRoles(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (db *DB) FindUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	q := db.list()
+	query, err := q.page(userColumns, "users", "created_at", page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0, page.Limit+1)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadUserRoles(ctx, users...); err != nil {
+		return nil, err
+	}
+
+	return domain.NewUsersPage(users, page), nil
+}
+
+func (db *DB) GetUserCredentials(ctx context.Context, email string) (*domain.Credentials, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("email", email)
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var creds domain.Credentials
+	err := db.stmts.userPassword.QueryRowContext(ctx, email).Scan(&creds.UserID, &creds.PasswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+func (db *DB) AddUserRole(ctx context.Context, id string, role domain.Role) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
+
+	return db.changeRoles(ctx, db.stmts.addRole, id, role)
+}
+
+func (db *DB) RemoveUserRole(ctx context.Context, id string, role domain.Role) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+	span.SetTag("role", role)
+
+	return db.changeRoles(ctx, db.stmts.removeRole, id, role)
+}
+
+// changeRoles runs a statement adding or removing a role of the user,
+// it succeeds whether the role was there or not.
+func (db *DB) changeRoles(ctx context.Context, stmt *sql.Stmt, id string, role domain.Role) error {
+	if !db.validID(id) {
+		return domain.ErrInvalidId
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, db.timeout)
+	defer cancel()
+
+	var exists bool
+	if err := db.stmts.userExists.QueryRowContext(ctx, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+
+	_, err := stmt.ExecContext(ctx, id, string(role))
+
+	return err
+}
+
+func (db *DB) loadUserRoles(ctx context.Context, users ...*domain.User) error {
+	for _, user := range users {
+		if err := db.loadRoles(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) loadRoles(ctx context.Context, user *domain.User) error {
+	rows, err := db.stmts.userRoles.QueryContext(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return err
+		}
+
+		user.Roles = append(user.Roles, domain.Role(role))
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/sqldb"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// SQLite runs one transaction at a time on the only connection, so rows
+// need no locks. Its LIKE folds case of ASCII letters only, so search
+// can't narrow down non-English queries.
+var dialect = sqldb.Dialect{
+	Name:        "sqlite",
+	Placeholder: "?",
+	NewID:       newID,
+	ValidID:     validID,
+	// Timestamps are stored as Unix nanoseconds, same as cursor keys.
+	Time: func(t time.Time) interface{} { return t.UnixNano() },
+	UniqueViolation: func(err error) bool {
+		var sqliteErr *sqlite.Error
+		return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	},
+	Migrations: migrationsFS,
+	MigrationsTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+}
+
+// New opens the SQLite database file, applies pending migrations and
+// prepares statements used by the adapter. The file is created if it
+// doesn't exist.
+func New(cfg *config.Config) (*sqldb.DB, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.SQLite.Timeout)
+	defer cancel()
+
+	conn, err := sql.Open("sqlite", dsn(cfg.SQLite.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer at a time. Sharing one connection
+	// serializes transactions in the pool instead of failing them with
+	// SQLITE_BUSY.
+	conn.SetMaxOpenConns(1)
+
+	return sqldb.New(ctx, conn, &dialect, cfg.SQLite.Timeout)
+}
+
+func dsn(path string) string {
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+}
+
+// newID returns a random identifier in the same format as Mongo object
+// IDs, so clients don't depend on the storage in use.
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func validID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 12
+}
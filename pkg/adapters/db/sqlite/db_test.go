@@ -0,0 +1,37 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/dbtest"
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/sqlite"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+func TestDB(t *testing.T) {
+	dbtest.Run(t, dbtest.Adapter{
+		New: func(t *testing.T) db.DB {
+			d, err := sqlite.New(&config.Config{
+				SQLite: config.SQLiteCfg{
+					Path:    filepath.Join(t.TempDir(), "shop.db"),
+					Timeout: 10 * time.Second,
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to open database: %v", err)
+			}
+
+			t.Cleanup(func() {
+				if err := d.Close(); err != nil {
+					t.Errorf("failed to close database: %v", err)
+				}
+			})
+
+			return d
+		},
+		UnknownID: "000000000000000000000000",
+	})
+}
@@ -0,0 +1,73 @@
+-- Timestamps are stored as Unix time in nanoseconds, so they compare
+-- and sort as plain integers.
+
+CREATE TABLE users (
+    id               TEXT PRIMARY KEY,
+    name             TEXT NOT NULL,
+    email            TEXT NOT NULL,
+    phone            TEXT NOT NULL,
+    created_at       INTEGER NOT NULL,
+    balance_amount   INTEGER NOT NULL DEFAULT 0,
+    balance_currency TEXT NOT NULL
+);
+
+CREATE TABLE items (
+    id             TEXT PRIMARY KEY,
+    owner_id       TEXT NOT NULL,
+    name           TEXT NOT NULL,
+    description    TEXT NOT NULL,
+    category       TEXT NOT NULL,
+    price_amount   INTEGER NOT NULL,
+    price_currency TEXT NOT NULL,
+    created_at     INTEGER NOT NULL,
+    quantity       INTEGER NOT NULL CHECK (quantity >= 0)
+);
+
+CREATE INDEX items_price_idx ON items (price_currency, price_amount);
+CREATE INDEX items_created_at_idx ON items (created_at);
+CREATE INDEX items_owner_id_idx ON items (owner_id);
+
+CREATE TABLE orders (
+    id             TEXT PRIMARY KEY,
+    customer_id    TEXT NOT NULL,
+    total_amount   INTEGER NOT NULL,
+    total_currency TEXT NOT NULL,
+    created_at     INTEGER NOT NULL,
+    status         TEXT NOT NULL
+);
+
+CREATE INDEX orders_customer_id_idx ON orders (customer_id, created_at);
+
+CREATE TABLE order_items (
+    order_id       TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    position       INTEGER NOT NULL,
+    item_id        TEXT NOT NULL,
+    quantity       INTEGER NOT NULL,
+    price_amount   INTEGER NOT NULL,
+    price_currency TEXT NOT NULL,
+    seller_id      TEXT,
+    PRIMARY KEY (order_id, position)
+);
+
+CREATE TABLE order_transitions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id    TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status TEXT NOT NULL,
+    to_status   TEXT NOT NULL,
+    at          INTEGER NOT NULL,
+    actor       TEXT NOT NULL
+);
+
+CREATE INDEX order_transitions_order_id_idx ON order_transitions (order_id, id);
+
+CREATE TABLE transactions (
+    id         TEXT PRIMARY KEY,
+    user_id    TEXT NOT NULL REFERENCES users (id),
+    order_id   TEXT,
+    kind       TEXT NOT NULL,
+    amount     INTEGER NOT NULL,
+    currency   TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX transactions_user_id_idx ON transactions (user_id, created_at);
@@ -0,0 +1,4 @@
+-- Keyset pagination sorts lists by a key and then by ID.
+
+CREATE INDEX users_created_at_idx ON users (created_at, id);
+CREATE INDEX items_price_amount_idx ON items (price_amount, id);
@@ -0,0 +1,4 @@
+-- Users log in with a password, only its bcrypt hash is stored. Users
+-- registered before have none and can't log in.
+
+ALTER TABLE users ADD COLUMN password_hash BLOB;
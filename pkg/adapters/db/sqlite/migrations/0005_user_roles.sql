@@ -0,0 +1,7 @@
+-- Roles granted to users. Buying needs no role, so most users have none.
+
+CREATE TABLE user_roles (
+    user_id TEXT NOT NULL REFERENCES users (id),
+    role    TEXT NOT NULL,
+    PRIMARY KEY (user_id, role)
+);
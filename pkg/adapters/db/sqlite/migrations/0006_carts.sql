@@ -0,0 +1,16 @@
+-- Clearing a cart deletes its items but keeps the cart row, so versions
+-- keep growing and a checkout of a stale cart can't clear a newer one.
+
+CREATE TABLE carts (
+    user_id    TEXT PRIMARY KEY REFERENCES users (id),
+    version    INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE cart_items (
+    user_id  TEXT NOT NULL REFERENCES carts (user_id),
+    item_id  TEXT NOT NULL REFERENCES items (id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    added_at INTEGER NOT NULL,
+    PRIMARY KEY (user_id, item_id)
+);
@@ -0,0 +1,13 @@
+-- Requests made with idempotency keys. The response columns are NULL
+-- while a request is being processed.
+
+CREATE TABLE idempotency_keys (
+    key          TEXT PRIMARY KEY,
+    fingerprint  TEXT NOT NULL,
+    status       INTEGER,
+    content_type TEXT,
+    body         BLOB,
+    expires_at   INTEGER NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  Report that the process is alive
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  domain.HealthReport
+// @Router       /healthz [get]
+func (s *Server) Healthz(c *gin.Context) {
+	c.JSON(200, &domain.HealthReport{Status: domain.HealthOK})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Report whether the service and its dependencies can serve requests
+// @Tags         Health
+// @Produce      json
+// @Success      200  {object}  domain.HealthReport
+// @Failure      503  {object}  domain.HealthReport
+// @Router       /readyz [get]
+func (s *Server) Readyz(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(503, &domain.HealthReport{Status: domain.HealthDraining})
+		return
+	}
+
+	report := s.shop.CheckHealth(c.Request.Context())
+	if report.Status != domain.HealthOK {
+		c.JSON(503, report)
+		return
+	}
+
+	c.JSON(200, report)
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+
+	v1 "github.com/Pavel7004/WebShop/pkg/adapters/http/v1"
+	"github.com/Pavel7004/WebShop/pkg/infra/metrics"
+)
+
+// TraceIDHeader carries the trace ID of the request back to the client,
+// so errors seen by the frontend can be found in Jaeger.
+const TraceIDHeader = "X-Trace-Id"
+
+const traceparentHeader = "traceparent"
+
+// tracingMiddleware starts a server span for every request and puts it
+// on the request context. The span continues the trace of the caller
+// if the request has OpenTracing or W3C traceparent headers.
+func tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tracer := opentracing.GlobalTracer()
+
+		opts := []opentracing.StartSpanOption{ext.SpanKindRPCServer}
+		if parent, ok := extractSpanContext(tracer, c); ok {
+			opts = append(opts, opentracing.ChildOf(parent))
+		}
+
+		span := tracer.StartSpan(operationName(c), opts...)
+		defer span.Finish()
+
+		ext.HTTPMethod.Set(span, c.Request.Method)
+		ext.HTTPUrl.Set(span, c.Request.URL.String())
+
+		c.Request = c.Request.WithContext(opentracing.ContextWithSpan(c.Request.Context(), span))
+
+		if traceID := v1.TraceID(c.Request.Context()); traceID != "" {
+			c.Header(TraceIDHeader, traceID)
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		ext.HTTPStatusCode.Set(span, uint16(status))
+		if status >= 500 {
+			ext.Error.Set(span, true)
+		}
+	}
+}
+
+// metricsMiddleware counts requests and measures their latency per
+// route pattern, so path parameters don't blow up label cardinality.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		labels := []string{c.Request.Method, routeOf(c), strconv.Itoa(c.Writer.Status())}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	}
+}
+
+func operationName(c *gin.Context) string {
+	return c.Request.Method + " " + routeOf(c)
+}
+
+func routeOf(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+
+	return "unknown route"
+}
+
+func extractSpanContext(tracer opentracing.Tracer, c *gin.Context) (opentracing.SpanContext, bool) {
+	carrier := opentracing.HTTPHeadersCarrier(c.Request.Header)
+	if sc, err := tracer.Extract(opentracing.HTTPHeaders, carrier); err == nil {
+		return sc, true
+	}
+
+	if sc, ok := parseTraceparent(c.GetHeader(traceparentHeader)); ok {
+		return sc, true
+	}
+
+	return nil, false
+}
+
+// parseTraceparent reads a W3C trace context header of the form
+// "00-<trace id>-<parent span id>-<flags>".
+func parseTraceparent(header string) (opentracing.SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, false
+	}
+
+	traceID, err := jaeger.TraceIDFromString(parts[1])
+	if err != nil || !traceID.IsValid() {
+		return nil, false
+	}
+
+	spanID, err := jaeger.SpanIDFromString(parts[2])
+	if err != nil || spanID == 0 {
+		return nil, false
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return nil, false
+	}
+
+	return jaeger.NewSpanContext(traceID, spanID, 0, flags&1 == 1, nil), true
+}
@@ -12,8 +12,20 @@ package http
 
 // @host      localhost:8080
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Access token from /shop/v1/auth/login as "Bearer <token>"
+
 import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"sync/atomic"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	v1 "github.com/Pavel7004/WebShop/pkg/adapters/http/v1"
 	"github.com/Pavel7004/WebShop/pkg/components"
@@ -21,8 +33,14 @@ import (
 )
 
 type Server struct {
-	router    *gin.Engine
-	isRunning bool
+	router *gin.Engine
+	server *nethttp.Server
+	cfg    *config.HTTPCfg
+	shop   components.Shop
+
+	// draining is set on shutdown, so readiness probes fail while
+	// in-flight requests finish.
+	draining atomic.Bool
 
 	v1 *v1.Handler
 }
@@ -31,34 +49,102 @@ func New(shop components.Shop, cfg *config.Config) *Server {
 	server := new(Server)
 
 	server.router = gin.New()
-	server.isRunning = false
+	server.cfg = &cfg.HTTP
+	server.shop = shop
 	server.v1 = v1.New(shop, cfg)
 
 	server.prepareRouter()
 
+	server.server = &nethttp.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      server.router,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+
 	return server
 }
 
+// Run serves requests until Shutdown is called. TLS is used when both
+// certificate and key files are configured.
 func (s *Server) Run() error {
-	s.isRunning = true
-	return s.router.Run(":8080")
+	var err error
+	if s.cfg.TLSCert != "" && s.cfg.TLSKey != "" {
+		err = s.server.ListenAndServeTLS(s.cfg.TLSCert, s.cfg.TLSKey)
+	} else {
+		err = s.server.ListenAndServe()
+	}
+
+	if errors.Is(err, nethttp.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown reports not-ready for the configured drain delay, so load
+// balancers stop sending traffic, then stops accepting connections and
+// waits for in-flight requests until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.draining.Store(true)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(s.cfg.DrainDelay):
+	}
+
+	return s.server.Shutdown(ctx)
 }
 
 func (s *Server) prepareRouter() {
+	// Probes and metrics are registered before the middleware, so they
+	// aren't traced or measured.
+	s.router.GET("/healthz", s.Healthz)
+	s.router.GET("/readyz", s.Readyz)
+	s.router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	s.router.Use(metricsMiddleware(), tracingMiddleware())
+
 	v1 := s.router.Group("/shop/v1")
 	{
-		v1.GET("/items/:item_id", s.v1.GetItem)             // -
-		v1.POST("/items/new", s.v1.AddItem)                 // -
-		v1.PUT("/items/:item_id", s.v1.UpdateItem)          // -
-		v1.GET("/items", s.v1.GetItems)                     // -
-		v1.GET("/items/recent", s.v1.GetRecentlyAddedItems) // -
-
-		v1.GET("/user/:user_id", s.v1.GetUser)                 // -
-		v1.POST("/user/new", s.v1.RegisterUser)                // -
-		v1.GET("/user/:user_id/items", s.v1.GetItemsByOwnerId) // -
-		v1.GET("/users/recent", s.v1.GetRecentlyAddedUsers)    // -
-
-		v1.POST("/orders/new", s.v1.CreateOrder) // -
+		v1.GET("/items/:item_id", s.v1.GetItem)                       // -
+		v1.POST("/items/new", s.v1.Authenticate, s.v1.AddItem)        // -
+		v1.PUT("/items/:item_id", s.v1.Authenticate, s.v1.UpdateItem) // -
+		v1.GET("/items", s.v1.GetItems)                               // -
+		v1.GET("/items/recent", s.v1.GetRecentlyAddedItems)           // -
+		v1.GET("/items/search", s.v1.SearchItems)                     // -
+
+		v1.GET("/user/:user_id", s.v1.Authenticate, s.v1.GetUser)                      // -
+		v1.POST("/user/new", s.v1.RegisterUser)                                        // -
+		v1.GET("/user/:user_id/items", s.v1.GetItemsByOwnerId)                         // -
+		v1.GET("/user/:user_id/orders", s.v1.Authenticate, s.v1.GetOrdersByCustomerId) // -
+		v1.GET("/users/recent", s.v1.Authenticate, s.v1.GetRecentlyAddedUsers)         // -
+
+		v1.POST("/user/:user_id/balance/topup", s.v1.Authenticate, s.v1.Idempotent, s.v1.TopUpBalance)       // -
+		v1.POST("/user/:user_id/balance/withdraw", s.v1.Authenticate, s.v1.Idempotent, s.v1.WithdrawBalance) // -
+		v1.GET("/user/:user_id/transactions", s.v1.Authenticate, s.v1.GetTransactions)                       // -
+
+		v1.GET("/user/:user_id/cart", s.v1.Authenticate, s.v1.GetCart)                             // -
+		v1.POST("/user/:user_id/cart/items", s.v1.Authenticate, s.v1.AddToCart)                    // -
+		v1.PUT("/user/:user_id/cart/items/:item_id", s.v1.Authenticate, s.v1.SetCartItem)          // -
+		v1.DELETE("/user/:user_id/cart/items/:item_id", s.v1.Authenticate, s.v1.RemoveFromCart)    // -
+		v1.DELETE("/user/:user_id/cart", s.v1.Authenticate, s.v1.ClearCart)                        // -
+		v1.POST("/user/:user_id/cart/checkout", s.v1.Authenticate, s.v1.Idempotent, s.v1.Checkout) // -
+
+		v1.POST("/auth/login", s.v1.Login)     // -
+		v1.POST("/auth/refresh", s.v1.Refresh) // -
+
+		v1.POST("/admin/users/:user_id/roles", s.v1.Authenticate, s.v1.GrantRole)          // -
+		v1.DELETE("/admin/users/:user_id/roles/:role", s.v1.Authenticate, s.v1.RevokeRole) // -
+
+		v1.POST("/orders/new", s.v1.Authenticate, s.v1.Idempotent, s.v1.CreateOrder)              // -
+		v1.GET("/orders/:order_id", s.v1.Authenticate, s.v1.GetOrder)                             // -
+		v1.POST("/orders/:order_id/pay", s.v1.Authenticate, s.v1.Idempotent, s.v1.PayOrder)       // -
+		v1.POST("/orders/:order_id/ship", s.v1.Authenticate, s.v1.ShipOrder)                      // -
+		v1.POST("/orders/:order_id/deliver", s.v1.Authenticate, s.v1.DeliverOrder)                // -
+		v1.POST("/orders/:order_id/cancel", s.v1.Authenticate, s.v1.CancelOrder)                  // -
+		v1.POST("/orders/:order_id/refund", s.v1.Authenticate, s.v1.Idempotent, s.v1.RefundOrder) // -
 	}
 
 	// query ?a=1&b=2 <- GET, DELETE не имеют тела
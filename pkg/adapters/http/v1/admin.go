@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// GrantRole godoc
+// @Summary     Grant role
+// @Description	Give a role to the user, admins only
+// @Tags        Admin
+// @Accept		json
+// @Produce     json
+// @Param       user_id  path  string              true  "User ID"
+// @Param       req      body  domain.RoleRequest  true  "Role to grant"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.User
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/admin/users/{user_id}/roles [post]
+func (h *Handler) GrantRole(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	var req domain.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	span.SetTag("role", req.Role)
+
+	user, err := h.shop.GrantRole(ctx, id, req.Role)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// RevokeRole godoc
+// @Summary     Revoke role
+// @Description	Take a role from the user, admins only
+// @Tags        Admin
+// @Produce     json
+// @Param       user_id  path  string  true  "User ID"
+// @Param       role     path  string  true  "Role to revoke"  Enums(seller, staff, admin)
+// @Security     BearerAuth
+// @Success      200  {object}  domain.User
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/admin/users/{user_id}/roles/{role} [delete]
+func (h *Handler) RevokeRole(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	role, err := domain.ParseRole(c.Param("role"))
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	span.SetTag("role", role)
+
+	user, err := h.shop.RevokeRole(ctx, id, role)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+const bearerPrefix = "Bearer "
+
+// Login godoc
+// @Summary     Log in
+// @Description	Check email and password and issue access and refresh tokens
+// @Tags        Auth
+// @Accept		json
+// @Produce     json
+// @Param       req  body  domain.LoginRequest  true  "Credentials"
+// @Success      200  {object}  domain.TokenPair
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/auth/login [post]
+func (h *Handler) Login(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	var req domain.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	tokens, err := h.shop.Login(ctx, &req)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, tokens)
+}
+
+// Refresh godoc
+// @Summary     Refresh tokens
+// @Description	Exchange a refresh token for a new pair of tokens
+// @Tags        Auth
+// @Accept		json
+// @Produce     json
+// @Param       req  body  domain.RefreshRequest  true  "Refresh token"
+// @Success      200  {object}  domain.TokenPair
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/auth/refresh [post]
+func (h *Handler) Refresh(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	var req domain.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	tokens, err := h.shop.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, tokens)
+}
+
+// Authenticate is a middleware that requires a valid access token in
+// the Authorization header. The ID of the user is put on the request
+// context, see domain.UserIDFromContext.
+func (h *Handler) Authenticate(c *gin.Context) {
+	userID, err := h.authenticate(c)
+	if err != nil {
+		h.SendError(c, err)
+		c.Abort()
+		return
+	}
+
+	c.Request = c.Request.WithContext(domain.WithUserID(c.Request.Context(), userID))
+	c.Next()
+}
+
+func (h *Handler) authenticate(c *gin.Context) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	header := c.GetHeader("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		c.Header("WWW-Authenticate", "Bearer")
+		return "", domain.ErrUnauthenticated
+	}
+
+	userID, err := h.shop.Authenticate(ctx, strings.TrimSpace(header[len(bearerPrefix):]))
+	if err != nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return "", err
+	}
+
+	span.SetTag("user_id", userID)
+
+	return userID, nil
+}
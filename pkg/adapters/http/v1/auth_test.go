@@ -0,0 +1,188 @@
+package v1_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+
+	v1 "github.com/Pavel7004/WebShop/pkg/adapters/http/v1"
+	"github.com/Pavel7004/WebShop/pkg/components"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+	"github.com/Pavel7004/WebShop/pkg/infra/token"
+)
+
+const (
+	secret = "test-secret"
+	userID = "user"
+)
+
+// tokenShop authenticates requests the way the shop does, by verifying
+// access tokens of its issuer.
+type tokenShop struct {
+	components.Shop
+
+	tokens *token.Issuer
+}
+
+func (s *tokenShop) Authenticate(_ context.Context, accessToken string) (string, error) {
+	return s.tokens.Verify(accessToken, domain.TokenAccess)
+}
+
+func newRouter(tokens *token.Issuer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	h := v1.New(&tokenShop{tokens: tokens}, &config.Config{})
+
+	r := gin.New()
+	r.GET("/me", h.Authenticate, func(c *gin.Context) {
+		id, _ := domain.UserIDFromContext(c.Request.Context())
+		c.String(http.StatusOK, id)
+	})
+
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	tokens := token.New(&config.AuthCfg{
+		Secret:     secret,
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+
+	pair, err := tokens.Issue(userID)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"kind": string(domain.TokenAccess),
+		"iss":  "webshop",
+		"sub":  userID,
+		"exp":  time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"kind": string(domain.TokenAccess),
+		"iss":  "webshop",
+		"sub":  userID,
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	sig := strings.LastIndexByte(pair.AccessToken, '.') + 1
+	tampered := pair.AccessToken[:sig] + flip(pair.AccessToken[sig]) + pair.AccessToken[sig+1:]
+
+	tests := []struct {
+		name      string
+		header    string
+		status    int
+		code      string
+		challenge string
+	}{
+		{
+			name:   "Valid",
+			header: "Bearer " + pair.AccessToken,
+			status: http.StatusOK,
+		},
+		{
+			name:      "NoHeader",
+			status:    http.StatusUnauthorized,
+			code:      "unauthenticated",
+			challenge: "Bearer",
+		},
+		{
+			name:      "OtherScheme",
+			header:    "Basic dXNlcjpwYXNz",
+			status:    http.StatusUnauthorized,
+			code:      "unauthenticated",
+			challenge: "Bearer",
+		},
+		{
+			name:      "Expired",
+			header:    "Bearer " + expired,
+			status:    http.StatusUnauthorized,
+			code:      "token_expired",
+			challenge: `Bearer error="invalid_token"`,
+		},
+		{
+			name:      "RefreshToken",
+			header:    "Bearer " + pair.RefreshToken,
+			status:    http.StatusUnauthorized,
+			code:      "token_invalid",
+			challenge: `Bearer error="invalid_token"`,
+		},
+		{
+			name:      "AlgNone",
+			header:    "Bearer " + none,
+			status:    http.StatusUnauthorized,
+			code:      "token_invalid",
+			challenge: `Bearer error="invalid_token"`,
+		},
+		{
+			name:      "TamperedSignature",
+			header:    "Bearer " + tampered,
+			status:    http.StatusUnauthorized,
+			code:      "token_invalid",
+			challenge: `Bearer error="invalid_token"`,
+		},
+	}
+
+	r := newRouter(tokens)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+
+			if tt.status == http.StatusOK {
+				if got := w.Body.String(); got != userID {
+					t.Errorf("user = %q, want %q", got, userID)
+				}
+				return
+			}
+
+			var p v1.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatalf("decode problem: %v", err)
+			}
+
+			if p.Code != tt.code {
+				t.Errorf("code = %q, want %q", p.Code, tt.code)
+			}
+		})
+	}
+}
+
+func flip(c byte) string {
+	if c == 'A' {
+		return "B"
+	}
+
+	return "A"
+}
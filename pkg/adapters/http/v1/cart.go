@@ -0,0 +1,228 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// GetCart godoc
+// @Summary     Get cart
+// @Description	Get the cart of the user priced at current prices. Lines that can't be ordered have a problem set
+// @Tags        Cart
+// @Produce     json
+// @Param       user_id  path  string  true  "User ID"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.CartView
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart [get]
+func (h *Handler) GetCart(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	cart, err := h.shop.GetCart(ctx, id)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, cart)
+}
+
+// AddToCart godoc
+// @Summary     Add item to cart
+// @Description	Add the item to the cart, or add to its quantity if it's already there. Fails with 409 if the cart changed meanwhile
+// @Tags        Cart
+// @Accept		json
+// @Produce     json
+// @Param       user_id  path  string                   true  "User ID"
+// @Param       req      body  domain.AddToCartRequest  true  "Item and quantity to add"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.CartView
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart/items [post]
+func (h *Handler) AddToCart(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	var req domain.AddToCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	span.SetTag("item_id", req.ItemID)
+	span.SetTag("quantity", req.Quantity)
+
+	cart, err := h.shop.AddToCart(ctx, id, req.ItemID, req.Quantity)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, cart)
+}
+
+// SetCartItem godoc
+// @Summary     Change cart item quantity
+// @Description	Set the quantity of an item that is already in the cart. Fails with 409 if the cart changed meanwhile
+// @Tags        Cart
+// @Accept		json
+// @Produce     json
+// @Param       user_id  path  string                         true  "User ID"
+// @Param       item_id  path  string                         true  "Item ID"
+// @Param       req      body  domain.SetCartQuantityRequest  true  "New quantity"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.CartView
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart/items/{item_id} [put]
+func (h *Handler) SetCartItem(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+	itemID := c.Param("item_id")
+
+	span.SetTag("user_id", id)
+	span.SetTag("item_id", itemID)
+
+	var req domain.SetCartQuantityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	span.SetTag("quantity", req.Quantity)
+
+	cart, err := h.shop.SetCartItem(ctx, id, itemID, req.Quantity)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, cart)
+}
+
+// RemoveFromCart godoc
+// @Summary     Remove item from cart
+// @Description	Remove the item from the cart
+// @Tags        Cart
+// @Produce     json
+// @Param       user_id  path  string  true  "User ID"
+// @Param       item_id  path  string  true  "Item ID"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.CartView
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart/items/{item_id} [delete]
+func (h *Handler) RemoveFromCart(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+	itemID := c.Param("item_id")
+
+	span.SetTag("user_id", id)
+	span.SetTag("item_id", itemID)
+
+	cart, err := h.shop.RemoveFromCart(ctx, id, itemID)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, cart)
+}
+
+// ClearCart godoc
+// @Summary     Clear cart
+// @Description	Remove all items from the cart
+// @Tags        Cart
+// @Param       user_id  path  string  true  "User ID"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart [delete]
+func (h *Handler) ClearCart(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	if err := h.shop.ClearCart(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.Status(200)
+}
+
+// Checkout godoc
+// @Summary     Check out cart
+// @Description	Place an order for everything in the cart and empty it. Fails with 409 if the cart changed meanwhile
+// @Tags        Cart
+// @Produce     json
+// @Param       user_id  path  string  true  "User ID"
+// @Param       Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
+// @Success      200  {object}  string
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/cart/checkout [post]
+func (h *Handler) Checkout(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	orderID, err := h.shop.Checkout(ctx, id)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	span.SetTag("order_id", orderID)
+
+	c.JSON(200, orderID)
+}
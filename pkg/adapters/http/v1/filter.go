@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// parseItemFilter reads catalog filters from query parameters. Every
+// invalid parameter is reported, like invalid fields of request bodies.
+func parseItemFilter(c *gin.Context) (domain.ItemFilter, error) {
+	var (
+		filter domain.ItemFilter
+		fields []domain.FieldError
+	)
+
+	invalid := func(field, reason string) {
+		fields = append(fields, domain.FieldError{Field: field, Reason: reason})
+	}
+
+	currency := domain.DefaultCurrency
+	if value, ok := c.GetQuery("currency"); ok {
+		parsed, err := domain.ParseCurrency(value)
+		if err != nil {
+			invalid("currency", "currency")
+		} else {
+			currency = parsed
+			filter.Currency = parsed
+		}
+	}
+
+	for _, bound := range []struct {
+		param string
+		dst   **domain.Money
+	}{
+		{"from", &filter.MinPrice},
+		{"to", &filter.MaxPrice},
+	} {
+		value, ok := c.GetQuery(bound.param)
+		if !ok {
+			continue
+		}
+
+		m, err := domain.ParseMoney(value, currency)
+		if err != nil {
+			invalid(bound.param, "money")
+			continue
+		}
+		*bound.dst = &m
+	}
+
+	for _, category := range c.QueryArray("category") {
+		if category != "" {
+			filter.Categories = append(filter.Categories, category)
+		}
+	}
+
+	if value, ok := c.GetQuery("in_stock"); ok {
+		inStock, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid("in_stock", "boolean")
+		}
+		filter.InStock = inStock
+	}
+
+	if value, ok := c.GetQuery("owner_id"); ok {
+		if !domain.IsID(value) {
+			invalid("owner_id", "id")
+		}
+		filter.OwnerID = value
+	}
+
+	if value, ok := c.GetQuery("created_after"); ok {
+		createdAfter, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			invalid("created_after", "datetime")
+		}
+		filter.CreatedAfter = createdAfter
+	}
+
+	if len(fields) > 0 {
+		return filter, domain.NewValidationError(fields)
+	}
+
+	return filter, nil
+}
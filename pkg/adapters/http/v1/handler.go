@@ -1,13 +1,34 @@
 package v1
 
 import (
+	"context"
+	"errors"
+
 	"github.com/gin-gonic/gin"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+	"github.com/uber/jaeger-client-go"
 
 	"github.com/Pavel7004/WebShop/pkg/components"
 	"github.com/Pavel7004/WebShop/pkg/domain"
 	"github.com/Pavel7004/WebShop/pkg/infra/config"
 )
 
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:webshop:problem:"
+)
+
+var statusByCategory = map[domain.Category]int{
+	domain.CategoryValidation:      400,
+	domain.CategoryUnauthenticated: 401,
+	domain.CategoryForbidden:       403,
+	domain.CategoryNotFound:        404,
+	domain.CategoryConflict:        409,
+	domain.CategoryPrecondition:    422,
+	domain.CategoryInternal:        500,
+}
+
 type Handler struct {
 	shop components.Shop
 	cfg  *config.Config
@@ -20,13 +41,64 @@ func New(shop components.Shop, cfg *config.Config) *Handler {
 	}
 }
 
+// Problem is an error response in the RFC 7807 format. Code and Details
+// repeat the domain error, TraceID points to the request trace.
+type Problem struct {
+	Type     string      `json:"type" example:"urn:webshop:problem:order_not_found"`
+	Title    string      `json:"title" example:"Order not found"`
+	Status   int         `json:"status" example:"404"`
+	Instance string      `json:"instance,omitempty" example:"/shop/v1/orders/6401c0b9d5a3f4a1b2c3d4e5"`
+	Code     string      `json:"code" example:"order_not_found"`
+	TraceID  string      `json:"trace_id,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
+
+// SendError writes err as a problem response. Errors that aren't domain
+// errors are logged and reported as internal without their message, so
+// storage or driver details don't leak to clients.
 func (h *Handler) SendError(c *gin.Context, err error) {
-	if e, ok := err.(*domain.Error); ok {
-		c.JSON(e.CodeHTTP, e)
-	} else {
-		c.JSON(500, &domain.Error{
-			Code:    "unknown_error",
-			Message: err.Error(),
-		})
+	traceID := TraceID(c.Request.Context())
+
+	var e *domain.Error
+	if !errors.As(err, &e) {
+		log.Error().Err(err).
+			Str("trace_id", traceID).
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Msg("Internal error")
+
+		e = domain.ErrInternal.(*domain.Error)
 	}
+
+	status, ok := statusByCategory[e.Category]
+	if !ok {
+		status = 500
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.JSON(status, &Problem{
+		Type:     problemTypePrefix + e.Code,
+		Title:    e.Message,
+		Status:   status,
+		Instance: c.Request.URL.Path,
+		Code:     e.Code,
+		TraceID:  traceID,
+		Details:  e.Details,
+	})
+}
+
+// TraceID returns the ID of the trace the request belongs to, or an
+// empty string if it isn't traced by Jaeger.
+func TraceID(ctx context.Context) string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+
+	sc, ok := span.Context().(jaeger.SpanContext)
+	if !ok {
+		return ""
+	}
+
+	return sc.TraceID().String()
 }
@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// Idempotent is a middleware for requests that move money. A request
+// with the Idempotency-Key header is processed once, retries with the
+// same key get its response replayed with the Idempotent-Replayed
+// header. Keys are fingerprinted with the method, URI and body of the
+// request, so reusing a key for another request fails. Requests without
+// the header are passed through.
+//
+// Responses to conflicts and server errors aren't stored: the request
+// may succeed when it's retried.
+func (h *Handler) Idempotent(c *gin.Context) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		c.Next()
+		return
+	}
+
+	fingerprint, resp, err := h.beginIdempotent(c, key)
+	if err != nil {
+		h.SendError(c, err)
+		c.Abort()
+		return
+	}
+
+	if resp != nil {
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(resp.Status, resp.ContentType, resp.Body)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.Next()
+
+	h.finishIdempotent(c, key, fingerprint, recorder)
+}
+
+func (h *Handler) beginIdempotent(c *gin.Context, key string) (string, *domain.IdempotentResponse, error) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return "", nil, domain.ErrMalformedRequest
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.New()
+	sum.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	sum.Write(body)
+	fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+	resp, err := h.shop.BeginIdempotent(ctx, key, fingerprint)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return fingerprint, resp, nil
+}
+
+func (h *Handler) finishIdempotent(c *gin.Context, key, fingerprint string, recorder *responseRecorder) {
+	// The client may be gone by now, the response is stored anyway so
+	// that its retry gets it.
+	ctx := opentracing.ContextWithSpan(context.Background(), opentracing.SpanFromContext(c.Request.Context()))
+	if userID, ok := domain.UserIDFromContext(c.Request.Context()); ok {
+		ctx = domain.WithUserID(ctx, userID)
+	}
+
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var resp *domain.IdempotentResponse
+	if status := recorder.Status(); status < 500 && status != 409 {
+		resp = &domain.IdempotentResponse{
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+	}
+
+	if err := h.shop.FinishIdempotent(ctx, key, fingerprint, resp); err != nil {
+		log.Error().Err(err).
+			Str("trace_id", TraceID(ctx)).
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Msg("Can't store idempotent response")
+	}
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -1,9 +1,6 @@
 package v1
 
 import (
-	"context"
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 
 	"github.com/Pavel7004/Common/tracing"
@@ -17,12 +14,12 @@ import (
 // @Produce      json
 // @Param        item_id   path      int  true  "Item ID"
 // @Success      200  {object}  domain.Item
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/items/{item_id} [get]
 func (h *Handler) GetItem(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	id := c.Param("item_id")
@@ -45,18 +42,22 @@ func (h *Handler) GetItem(c *gin.Context) {
 // @Accept		json
 // @Produce     json
 // @Param       req	  body  domain.AddItemRequest	true  "Request to add an item"
+// @Security     BearerAuth
 // @Success      200  {object}  string
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/items/new [post]
 func (h *Handler) AddItem(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	var req domain.AddItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.SendError(c, err)
+		h.SendError(c, requestError(err))
 		return
 	}
 
@@ -73,46 +74,47 @@ func (h *Handler) AddItem(c *gin.Context) {
 	c.JSON(200, id)
 }
 
-// GetItemsByPrice godoc
-// @Summary     Get items within price range
-// @Description	Get items with specified price range
+// GetItems godoc
+// @Summary     Get items
+// @Description	Get catalog items matching all given filters. The first page also has facets: item counts per category and per price range. Each facet ignores its own filter, so the counts show what choosing another category or price range would give.
 // @Tags        Items
 // @Produce     json
-// @Param       from	query	float64	false  "Price lower bound"
-// @Param       to		query	float64	false  "Price upper bound"
-// @Success      200  {object}  []domain.Item
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Param       category		query	[]string	false  "Item category, repeat to match any of several"  collectionFormat(multi)
+// @Param       from			query	string		false  "Price lower bound, e.g. 10.50"
+// @Param       to				query	string		false  "Price upper bound, e.g. 99.99"
+// @Param       currency		query	string		false  "Price currency"
+// @Param       in_stock		query	bool		false  "Only items with quantity above zero"
+// @Param       owner_id		query	string		false  "Owner ID"
+// @Param       created_after	query	string		false  "Only items added since the time, RFC 3339"
+// @Param       limit			query	int			false  "Maximum number of items"
+// @Param       sort			query	string		false  "Sort order"  Enums(newest, oldest, price_asc, price_desc)  default(newest)
+// @Param       cursor			query	string		false  "Cursor of the next page from the previous response"
+// @Success      200  {object}  domain.ItemsPage
+// @Failure      400  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/items [get]
 func (h *Handler) GetItems(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
-	var (
-		fromStr = c.DefaultQuery("from", "0")
-		toStr   = c.DefaultQuery("to", "99999999")
-	)
-
-	span.SetTag("from_query", fromStr)
-	span.SetTag("to_query", toStr)
-
-	from, err := strconv.ParseFloat(fromStr, 64)
+	filter, err := parseItemFilter(c)
 	if err != nil {
 		h.SendError(c, err)
 		return
 	}
 
-	to, err := strconv.ParseFloat(toStr, 64)
+	span.SetTag("filter", filter)
+
+	page, err := h.parseListPage(c, h.cfg.PageLimit, domain.ItemSorts)
 	if err != nil {
 		h.SendError(c, err)
 		return
 	}
 
-	span.SetTag("from_parsed", from)
-	span.SetTag("to_parsed", to)
+	span.SetTag("limit", page.Limit)
+	span.SetTag("sort", page.Sort)
 
-	items, err := h.shop.GetItemsByPrice(ctx, from, to)
+	items, err := h.shop.FindItems(ctx, filter, page)
 	if err != nil {
 		h.SendError(c, err)
 		return
@@ -126,16 +128,28 @@ func (h *Handler) GetItems(c *gin.Context) {
 // @Description	Get items that was added within last 3 days
 // @Tags        Items
 // @Produce     json
-// @Success      200  {object}  []domain.Item
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Param       limit   query  int     false  "Maximum number of items"
+// @Param       sort    query  string  false  "Sort order"  Enums(newest, oldest, price_asc, price_desc)  default(newest)
+// @Param       cursor  query  string  false  "Cursor of the next page from the previous response"
+// @Success      200  {object}  domain.ItemsPage
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/items/recent [get]
 func (h *Handler) GetRecentlyAddedItems(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
-	items, err := h.shop.GetRecentlyAddedItems(ctx, h.cfg.RecentItemsPeriod)
+	page, err := h.parseListPage(c, h.cfg.PageLimit, domain.ItemSorts)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	span.SetTag("limit", page.Limit)
+	span.SetTag("sort", page.Sort)
+
+	items, err := h.shop.GetRecentlyAddedItems(ctx, h.cfg.RecentItemsPeriod, page)
 	if err != nil {
 		h.SendError(c, err)
 		return
@@ -144,6 +158,40 @@ func (h *Handler) GetRecentlyAddedItems(c *gin.Context) {
 	c.JSON(200, items)
 }
 
+// SearchItems godoc
+// @Summary     Search items
+// @Description	Find items by words in their name, category or description, most relevant first. Highlights are HTML: the text is escaped and matched words are wrapped in <em> tags.
+// @Tags        Items
+// @Produce     json
+// @Param       q		query	string	true   "Search query"
+// @Param       limit	query	int		false  "Maximum number of items"
+// @Success      200  {object}  domain.SearchResult
+// @Failure      400  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/items/search [get]
+func (h *Handler) SearchItems(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	query := c.Query("q")
+
+	span.SetTag("query", query)
+
+	limit, err := h.parseLimit(c, h.cfg.PageLimit)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	result, err := h.shop.SearchItems(ctx, domain.SearchRequest{Query: query, Limit: limit})
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, result)
+}
+
 // UpdateItem godoc
 // @Summary     Update item info
 // @Description	Update item entry
@@ -152,13 +200,17 @@ func (h *Handler) GetRecentlyAddedItems(c *gin.Context) {
 // @Produce     json
 // @Param       req	  	body  	domain.UpdateItemRequest	true  "Request to update info in item"
 // @Param       item_id	path	string 						true  "Item id"
+// @Security     BearerAuth
 // @Success      200  {object}  int
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/items/{item_id} [put]
 func (h *Handler) UpdateItem(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	id := c.Param("item_id")
@@ -167,7 +219,7 @@ func (h *Handler) UpdateItem(c *gin.Context) {
 
 	var req domain.UpdateItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.SendError(c, err)
+		h.SendError(c, requestError(err))
 		return
 	}
 
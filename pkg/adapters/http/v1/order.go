@@ -1,8 +1,6 @@
 package v1
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 
 	"github.com/Pavel7004/Common/tracing"
@@ -16,43 +14,224 @@ import (
 // @Accept		json
 // @Produce     json
 // @Param       req	  body  domain.CreateOrderRequest	true  "Request to create an order"
+// @Param       Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
 // @Success      200  {object}  string
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/orders/new [post]
 func (h *Handler) CreateOrder(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	var req domain.CreateOrderRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.SendError(c, err)
+		h.SendError(c, requestError(err))
 		return
 	}
 
-	if err := ValidateOrder(&req); err != nil {
+	id, err := h.shop.CreateOrder(ctx, &req)
+	if err != nil {
 		h.SendError(c, err)
 		return
 	}
 
-	id, err := h.shop.CreateOrder(ctx, &req)
+	span.SetTag("order_id", id)
+
+	c.JSON(200, id)
+}
+
+// GetOrder godoc
+// @Summary      Get order
+// @Description  Get order by ID
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.Order
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id} [get]
+func (h *Handler) GetOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
+	span.SetTag("order_id", id)
+
+	order, err := h.shop.GetOrderById(ctx, id)
 	if err != nil {
 		h.SendError(c, err)
 		return
 	}
 
+	c.JSON(200, order)
+}
+
+// PayOrder godoc
+// @Summary      Pay order
+// @Description  Charge order total from customer balance and mark order as paid
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Param        Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id}/pay [post]
+func (h *Handler) PayOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
 	span.SetTag("order_id", id)
 
-	c.JSON(200, id)
+	if err := h.shop.PayOrder(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.Status(200)
 }
 
-func ValidateOrder(req *domain.CreateOrderRequest) error {
-	for _, item := range req.Items {
-		if item.Quantity <= 0 {
-			return domain.ErrOrderQuantity
-		}
+// ShipOrder godoc
+// @Summary      Ship order
+// @Description  Mark paid order as shipped
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id}/ship [post]
+func (h *Handler) ShipOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
+	span.SetTag("order_id", id)
+
+	if err := h.shop.ShipOrder(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.Status(200)
+}
+
+// DeliverOrder godoc
+// @Summary      Deliver order
+// @Description  Mark paid or shipped order as delivered
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id}/deliver [post]
+func (h *Handler) DeliverOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
+	span.SetTag("order_id", id)
+
+	if err := h.shop.ProcessOrder(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.Status(200)
+}
+
+// CancelOrder godoc
+// @Summary      Cancel order
+// @Description  Cancel order that isn't paid yet
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id}/cancel [post]
+func (h *Handler) CancelOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
+	span.SetTag("order_id", id)
+
+	if err := h.shop.CancelOrder(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.Status(200)
+}
+
+// RefundOrder godoc
+// @Summary      Refund order
+// @Description  Return money of paid, shipped or delivered order to customer
+// @Tags         Orders
+// @Produce      json
+// @Param        order_id  path  string  true  "Order ID"
+// @Param        Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
+// @Success      200
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/orders/{order_id}/refund [post]
+func (h *Handler) RefundOrder(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("order_id")
+
+	span.SetTag("order_id", id)
+
+	if err := h.shop.RefundOrder(ctx, id); err != nil {
+		h.SendError(c, err)
+		return
 	}
 
-	return nil
+	c.Status(200)
 }
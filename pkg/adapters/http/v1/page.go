@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// parseListPage reads the limit, sort and cursor query parameters of a
+// list endpoint. The first of sorts is the default order.
+func (h *Handler) parseListPage(c *gin.Context, defaultLimit int64, sorts []domain.SortOrder) (domain.PageRequest, error) {
+	page := domain.PageRequest{Sort: sorts[0]}
+
+	var err error
+	page.Limit, err = h.parseLimit(c, defaultLimit)
+	if err != nil {
+		return page, err
+	}
+
+	if sortStr, ok := c.GetQuery("sort"); ok {
+		page.Sort, err = domain.ParseSortOrder(sortStr, sorts)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		page.After, err = domain.DecodeCursor(cursor, page.Sort)
+		if err != nil {
+			return page, err
+		}
+	}
+
+	return page, nil
+}
+
+// parseLimit reads the limit query parameter, capped by the configured
+// maximum.
+func (h *Handler) parseLimit(c *gin.Context, defaultLimit int64) (int64, error) {
+	limit := defaultLimit
+	if limitStr, ok := c.GetQuery("limit"); ok {
+		var err error
+		limit, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit <= 0 {
+			return 0, domain.ErrInvalidPagination
+		}
+	}
+
+	if limit > h.cfg.MaxPageLimit {
+		limit = h.cfg.MaxPageLimit
+	}
+
+	return limit, nil
+}
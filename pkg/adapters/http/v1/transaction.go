@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// TopUpBalance godoc
+// @Summary     Top up balance
+// @Description	Add money paid outside of the shop to user balance, staff only
+// @Tags        Balance
+// @Accept		json
+// @Produce     json
+// @Param       user_id  path  string               true  "User ID"
+// @Param       req      body  domain.TopUpRequest  true  "Amount to add"
+// @Param       Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.Transaction
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/balance/topup [post]
+func (h *Handler) TopUpBalance(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	var req domain.TopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	span.SetTag("amount", req.Amount.String())
+
+	tx, err := h.shop.TopUpBalance(ctx, id, req.Amount)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, tx)
+}
+
+// WithdrawBalance godoc
+// @Summary     Withdraw from balance
+// @Description	Take money paid out to the user outside of the shop from user balance, staff only
+// @Tags        Balance
+// @Accept		json
+// @Produce     json
+// @Param       user_id  path  string                  true  "User ID"
+// @Param       req      body  domain.WithdrawRequest  true  "Amount to take"
+// @Param       Idempotency-Key  header  string  false  "Key to retry the request without repeating it"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.Transaction
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      422  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/balance/withdraw [post]
+func (h *Handler) WithdrawBalance(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	var req domain.WithdrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.SendError(c, requestError(err))
+		return
+	}
+
+	span.SetTag("amount", req.Amount.String())
+
+	tx, err := h.shop.WithdrawBalance(ctx, id, req.Amount)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, tx)
+}
+
+// GetTransactions godoc
+// @Summary     Get balance history
+// @Description	Get ledger entries of the user, newest first by default
+// @Tags        Balance
+// @Produce     json
+// @Param       user_id  path   string  true   "User ID"
+// @Param       limit    query  int     false  "Maximum number of entries"
+// @Param       sort     query  string  false  "Sort order"  Enums(newest, oldest)  default(newest)
+// @Param       cursor   query  string  false  "Cursor of the next page from the previous response"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.TransactionsPage
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/transactions [get]
+func (h *Handler) GetTransactions(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	page, err := h.parseListPage(c, h.cfg.PageLimit, domain.TransactionSorts)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	txs, err := h.shop.GetTransactions(ctx, id, page)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, txs)
+}
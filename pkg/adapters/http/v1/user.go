@@ -1,8 +1,6 @@
 package v1
 
 import (
-	"context"
-
 	"github.com/gin-gonic/gin"
 
 	"github.com/Pavel7004/Common/tracing"
@@ -15,13 +13,16 @@ import (
 // @Tags         Users
 // @Produce      json
 // @Param        user_id  path  int  true  "user ID"
+// @Security     BearerAuth
 // @Success      200  {object}  domain.User
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/user/{user_id} [get]
 func (h *Handler) GetUser(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	id := c.Param("user_id")
@@ -45,17 +46,18 @@ func (h *Handler) GetUser(c *gin.Context) {
 // @Produce     json
 // @Param       req  body  domain.RegisterUserRequest	true  "Request to register new user"
 // @Success      200  {object}  string
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      409  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/user/new [post]
 func (h *Handler) RegisterUser(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	var req domain.RegisterUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		h.SendError(c, err)
+		h.SendError(c, requestError(err))
 		return
 	}
 
@@ -73,20 +75,33 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 // @Description	Get all items that were created by user
 // @Tags        Users
 // @Produce     json
-// @Success      200  {object}  []domain.Item
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Param       user_id  path   string  true   "User ID"
+// @Param       limit    query  int     false  "Maximum number of items"
+// @Param       sort     query  string  false  "Sort order"  Enums(newest, oldest, price_asc, price_desc)  default(newest)
+// @Param       cursor   query  string  false  "Cursor of the next page from the previous response"
+// @Success      200  {object}  domain.ItemsPage
+// @Failure      400  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/user/{user_id}/items [get]
 func (h *Handler) GetItemsByOwnerId(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
 	id := c.Param("user_id")
 
 	span.SetTag("user_id", id)
 
-	items, err := h.shop.GetItemsByOwnerId(ctx, id)
+	page, err := h.parseListPage(c, h.cfg.PageLimit, domain.ItemSorts)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	span.SetTag("limit", page.Limit)
+	span.SetTag("sort", page.Sort)
+
+	items, err := h.shop.GetItemsByOwnerId(ctx, id, page)
 	if err != nil {
 		h.SendError(c, err)
 		return
@@ -95,21 +110,76 @@ func (h *Handler) GetItemsByOwnerId(c *gin.Context) {
 	c.JSON(200, items)
 }
 
+// GetOrdersByCustomerId godoc
+// @Summary     Get orders placed by 'user_id'
+// @Description	Get orders of the user, newest first by default
+// @Tags        Users
+// @Produce     json
+// @Param       user_id  path   string  true   "User ID"
+// @Param       limit    query  int     false  "Maximum number of orders"
+// @Param       sort     query  string  false  "Sort order"  Enums(newest, oldest)  default(newest)
+// @Param       cursor   query  string  false  "Cursor of the next page from the previous response"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.OrdersPage
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
+// @Router       /shop/v1/user/{user_id}/orders [get]
+func (h *Handler) GetOrdersByCustomerId(c *gin.Context) {
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
+	defer span.Finish()
+
+	id := c.Param("user_id")
+
+	span.SetTag("user_id", id)
+
+	page, err := h.parseListPage(c, h.cfg.PageLimit, domain.OrderSorts)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	orders, err := h.shop.GetOrdersByCustomerId(ctx, id, page)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	c.JSON(200, orders)
+}
+
 // GetRecentlyAddedUsers godoc
 // @Summary     Get recenly added users
-// @Description	Get last 2 added users
+// @Description	Get registered users, newest first by default
 // @Tags        Users
 // @Produce     json
-// @Success      200  {object}  []domain.User
-// @Failure      400  {object}  domain.Error
-// @Failure      404  {object}  domain.Error
-// @Failure      500  {object}  domain.Error
+// @Param       limit   query  int     false  "Maximum number of users"
+// @Param       sort    query  string  false  "Sort order"  Enums(newest, oldest)  default(newest)
+// @Param       cursor  query  string  false  "Cursor of the next page from the previous response"
+// @Security     BearerAuth
+// @Success      200  {object}  domain.UsersPage
+// @Failure      400  {object}  Problem
+// @Failure      401  {object}  Problem
+// @Failure      403  {object}  Problem
+// @Failure      404  {object}  Problem
+// @Failure      500  {object}  Problem
 // @Router       /shop/v1/users/recent [get]
 func (h *Handler) GetRecentlyAddedUsers(c *gin.Context) {
-	span, ctx := tracing.StartSpanFromContext(context.Background())
+	span, ctx := tracing.StartSpanFromContext(c.Request.Context())
 	defer span.Finish()
 
-	users, err := h.shop.GetRecentlyAddedUsers(ctx, h.cfg.RecentUsersCount)
+	page, err := h.parseListPage(c, h.cfg.RecentUsersCount, domain.UserSorts)
+	if err != nil {
+		h.SendError(c, err)
+		return
+	}
+
+	span.SetTag("limit", page.Limit)
+	span.SetTag("sort", page.Sort)
+
+	users, err := h.shop.GetRecentlyAddedUsers(ctx, page)
 	if err != nil {
 		h.SendError(c, err)
 		return
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Validation rules are declared with `binding` tags on domain request
+// types and checked by gin when the body is bound.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(jsonFieldName)
+	v.RegisterCustomTypeFunc(moneyAmount, domain.Money{})
+
+	if err := v.RegisterValidation("id", validateID); err != nil {
+		panic(err)
+	}
+	if err := v.RegisterValidation("maxbytes", validateMaxBytes); err != nil {
+		panic(err)
+	}
+}
+
+// jsonFieldName makes field errors refer to fields by their JSON names.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+
+	return name
+}
+
+func moneyAmount(v reflect.Value) interface{} {
+	if m, ok := v.Interface().(domain.Money); ok {
+		return m.Amount
+	}
+
+	return nil
+}
+
+func validateID(fl validator.FieldLevel) bool {
+	return domain.IsID(fl.Field().String())
+}
+
+// validateMaxBytes limits the length of a string in bytes rather than
+// in characters like max does.
+func validateMaxBytes(fl validator.FieldLevel) bool {
+	n, err := strconv.Atoi(fl.Param())
+	if err != nil {
+		panic(err)
+	}
+
+	return len(fl.Field().String()) <= n
+}
+
+// requestError converts errors of binding a request body to domain
+// errors: rule violations are listed per field, undecodable bodies are
+// reported as malformed.
+func requestError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]domain.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, domain.FieldError{
+				Field:  fieldPath(fe.Namespace()),
+				Reason: fe.Tag(),
+				Param:  fe.Param(),
+			})
+		}
+
+		return domain.NewValidationError(fields)
+	}
+
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return domain.NewValidationError([]domain.FieldError{{
+			Field:  typeErr.Field,
+			Reason: "type",
+			Param:  typeErr.Type.String(),
+		}})
+	}
+
+	return domain.WithDetails(domain.ErrMalformedRequest, err.Error())
+}
+
+// fieldPath strips the request type name from a validator namespace:
+// "CreateOrderRequest.items[0].quantity" becomes "items[0].quantity".
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+
+	return path
+}
@@ -11,25 +11,88 @@ type Items interface {
 	AddItem(ctx context.Context, item *domain.AddItemRequest) (string, error)
 	UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error)
 	GetItemById(ctx context.Context, id string) (*domain.Item, error)
-	GetItemsByPrice(ctx context.Context, from, to float64) ([]*domain.Item, error)
-	GetRecentlyAddedItems(ctx context.Context, period time.Duration) ([]*domain.Item, error)
-	GetItemsByOwnerId(ctx context.Context, id string) ([]*domain.Item, error)
+	FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error)
+	GetRecentlyAddedItems(ctx context.Context, period time.Duration, page domain.PageRequest) (*domain.ItemsPage, error)
+	GetItemsByOwnerId(ctx context.Context, id string, page domain.PageRequest) (*domain.ItemsPage, error)
+	SearchItems(ctx context.Context, req domain.SearchRequest) (*domain.SearchResult, error)
 }
 
 type Users interface {
 	RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (string, error)
 	GetUserById(ctx context.Context, id string) (*domain.User, error)
-	GetRecentlyAddedUsers(ctx context.Context, count int64) ([]*domain.User, error)
+	GetRecentlyAddedUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error)
+	GrantRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error)
+	RevokeRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error)
 }
 
 type Orders interface {
 	CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (string, error)
+	GetOrderById(ctx context.Context, id string) (*domain.Order, error)
+	GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error)
 	PayOrder(ctx context.Context, orderID string) error
+	ShipOrder(ctx context.Context, orderID string) error
 	ProcessOrder(ctx context.Context, orderID string) error
+	CancelOrder(ctx context.Context, orderID string) error
+	RefundOrder(ctx context.Context, orderID string) error
+	ExpireOrder(ctx context.Context, orderID string) error
+}
+
+// Carts keep what users are going to buy. Carts are returned priced at
+// current prices, see domain.CartView.
+type Carts interface {
+	GetCart(ctx context.Context, userID string) (*domain.CartView, error)
+	// AddToCart adds the quantity to the one of the item already in the
+	// cart.
+	AddToCart(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error)
+	SetCartItem(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error)
+	RemoveFromCart(ctx context.Context, userID, itemID string) (*domain.CartView, error)
+	ClearCart(ctx context.Context, userID string) error
+	// Checkout orders everything in the cart and empties it. It returns
+	// the ID of the order.
+	Checkout(ctx context.Context, userID string) (string, error)
+}
+
+type Ledger interface {
+	TopUpBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error)
+	// WithdrawBalance debits money paid out to the user. It fails with
+	// ErrInsufficientFunds if the balance is short of the amount.
+	WithdrawBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error)
+	GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error)
+	RecomputeBalance(ctx context.Context, userID string) (domain.Money, error)
+}
+
+type Auth interface {
+	Login(ctx context.Context, req *domain.LoginRequest) (*domain.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, error)
+	// Authenticate verifies an access token and returns the ID of the
+	// user it was issued to.
+	Authenticate(ctx context.Context, accessToken string) (string, error)
+}
+
+// Idempotency lets clients retry requests safely. A request made with an
+// idempotency key is processed once, retries with the same key get the
+// stored response. Keys are scoped to the caller.
+type Idempotency interface {
+	// BeginIdempotent returns the stored response if the request was
+	// already made with the key. Otherwise it returns nil and the key
+	// is taken until the request is finished with FinishIdempotent.
+	BeginIdempotent(ctx context.Context, key, fingerprint string) (*domain.IdempotentResponse, error)
+	// FinishIdempotent stores the response to replay it on retries. If
+	// resp is nil, the key is released so the request can be retried.
+	FinishIdempotent(ctx context.Context, key, fingerprint string, resp *domain.IdempotentResponse) error
+}
+
+type Health interface {
+	CheckHealth(ctx context.Context) *domain.HealthReport
 }
 
 type Shop interface {
 	Items
 	Users
 	Orders
+	Carts
+	Ledger
+	Auth
+	Idempotency
+	Health
 }
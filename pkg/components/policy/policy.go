@@ -0,0 +1,430 @@
+// Package policy decides who may do what in the shop.
+package policy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Pavel7004/Common/tracing"
+	"github.com/Pavel7004/WebShop/pkg/components"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Policy is a components.Shop that checks permissions of the caller
+// before passing requests on to the shop. The caller is the user the
+// request was authenticated as, see domain.UserIDFromContext.
+//
+// Methods that aren't overridden here, like reading the catalog,
+// registration and login, are open to anyone.
+type Policy struct {
+	components.Shop
+}
+
+var _ components.Shop = (*Policy)(nil)
+
+func New(shop components.Shop) *Policy {
+	return &Policy{
+		Shop: shop,
+	}
+}
+
+// AddItem lets sellers add items they own. Admins may add items of
+// anyone.
+func (p *Policy) AddItem(ctx context.Context, item *domain.AddItemRequest) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	caller, err := p.requireRole(ctx, domain.RoleSeller)
+	if err != nil {
+		return "", err
+	}
+
+	if item != nil && item.OwnerID != caller.ID && !caller.HasRole(domain.RoleAdmin) {
+		return "", domain.ErrForbidden
+	}
+
+	return p.Shop.AddItem(ctx, item)
+}
+
+func (p *Policy) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	item, err := p.Shop.GetItemById(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if item.OwnerID != caller.ID && !caller.HasRole(domain.RoleAdmin) {
+		return 0, domain.ErrForbidden
+	}
+
+	return p.Shop.UpdateItem(ctx, id, in)
+}
+
+// GetUserById shows users their own profiles. Profiles hold contacts
+// and balances, so only staff may see profiles of others.
+func (p *Policy) GetUserById(ctx context.Context, id string) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, id, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GetUserById(ctx, id)
+}
+
+func (p *Policy) GetRecentlyAddedUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GetRecentlyAddedUsers(ctx, page)
+}
+
+// CreateOrder lets users order for themselves only, since orders are
+// paid from the balance of the customer.
+func (p *Policy) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if req != nil && req.CustomerID != caller.ID {
+		return "", domain.ErrForbidden
+	}
+
+	return p.Shop.CreateOrder(ctx, req)
+}
+
+func (p *Policy) GetOrderById(ctx context.Context, id string) (*domain.Order, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := p.Shop.GetOrderById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.CustomerID != caller.ID && !caller.HasRole(domain.RoleStaff) {
+		return nil, domain.ErrForbidden
+	}
+
+	return order, nil
+}
+
+func (p *Policy) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, id, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GetOrdersByCustomerId(ctx, id, page)
+}
+
+// PayOrder lets only the customer pay, not even staff can spend money
+// of users.
+func (p *Policy) PayOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if err := p.requireCustomer(ctx, orderID, ""); err != nil {
+		return err
+	}
+
+	return p.Shop.PayOrder(ctx, orderID)
+}
+
+func (p *Policy) ShipOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return err
+	}
+
+	return p.Shop.ShipOrder(ctx, orderID)
+}
+
+func (p *Policy) ProcessOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return err
+	}
+
+	return p.Shop.ProcessOrder(ctx, orderID)
+}
+
+func (p *Policy) CancelOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if err := p.requireCustomer(ctx, orderID, domain.RoleStaff); err != nil {
+		return err
+	}
+
+	return p.Shop.CancelOrder(ctx, orderID)
+}
+
+func (p *Policy) RefundOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return err
+	}
+
+	return p.Shop.RefundOrder(ctx, orderID)
+}
+
+func (p *Policy) ExpireOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return err
+	}
+
+	return p.Shop.ExpireOrder(ctx, orderID)
+}
+
+// GetCart lets users see their own carts. Staff may see carts of anyone,
+// but only owners change them.
+func (p *Policy) GetCart(ctx context.Context, userID string) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GetCart(ctx, userID)
+}
+
+func (p *Policy) AddToCart(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, ""); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.AddToCart(ctx, userID, itemID, quantity)
+}
+
+func (p *Policy) SetCartItem(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, ""); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.SetCartItem(ctx, userID, itemID, quantity)
+}
+
+func (p *Policy) RemoveFromCart(ctx context.Context, userID, itemID string) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, ""); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.RemoveFromCart(ctx, userID, itemID)
+}
+
+func (p *Policy) ClearCart(ctx context.Context, userID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, ""); err != nil {
+		return err
+	}
+
+	return p.Shop.ClearCart(ctx, userID)
+}
+
+func (p *Policy) Checkout(ctx context.Context, userID string) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, ""); err != nil {
+		return "", err
+	}
+
+	return p.Shop.Checkout(ctx, userID)
+}
+
+// TopUpBalance lets staff credit money users paid outside of the shop.
+// The shop takes no payments itself, so users can't top up on their own.
+func (p *Policy) TopUpBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.TopUpBalance(ctx, userID, amount)
+}
+
+// WithdrawBalance lets staff debit money paid out to users outside of
+// the shop, the same way they credit top-ups.
+func (p *Policy) WithdrawBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.WithdrawBalance(ctx, userID, amount)
+}
+
+func (p *Policy) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireSelfOr(ctx, userID, domain.RoleStaff); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GetTransactions(ctx, userID, page)
+}
+
+func (p *Policy) RecomputeBalance(ctx context.Context, userID string) (domain.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleStaff); err != nil {
+		return domain.Money{}, err
+	}
+
+	return p.Shop.RecomputeBalance(ctx, userID)
+}
+
+func (p *Policy) GrantRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.GrantRole(ctx, userID, role)
+}
+
+func (p *Policy) RevokeRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if _, err := p.requireRole(ctx, domain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return p.Shop.RevokeRole(ctx, userID, role)
+}
+
+// caller returns the user making the request. Requests without one,
+// or made by a user that no longer exists, aren't authenticated.
+func (p *Policy) caller(ctx context.Context) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	id, ok := domain.UserIDFromContext(ctx)
+	if !ok {
+		return nil, domain.ErrUnauthenticated
+	}
+
+	span.SetTag("caller_id", id)
+
+	user, err := p.Shop.GetUserById(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrUnauthenticated
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (p *Policy) requireRole(ctx context.Context, role domain.Role) (*domain.User, error) {
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !caller.HasRole(role) {
+		return nil, denied(role)
+	}
+
+	return caller, nil
+}
+
+// requireSelfOr allows the user with the ID, or anyone with the role
+// if it's set.
+func (p *Policy) requireSelfOr(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if caller.ID != userID && (role == "" || !caller.HasRole(role)) {
+		return nil, domain.ErrForbidden
+	}
+
+	return caller, nil
+}
+
+// requireCustomer allows the customer of the order, or anyone with the
+// role if it's set.
+func (p *Policy) requireCustomer(ctx context.Context, orderID string, role domain.Role) error {
+	caller, err := p.caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if role != "" && caller.HasRole(role) {
+		return nil
+	}
+
+	order, err := p.Shop.GetOrderById(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	if order.CustomerID != caller.ID {
+		return domain.ErrForbidden
+	}
+
+	return nil
+}
+
+// denied reports the role the caller lacks.
+func denied(role domain.Role) error {
+	return domain.WithDetails(domain.ErrForbidden, map[string]domain.Role{"required_role": role})
+}
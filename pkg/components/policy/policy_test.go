@@ -0,0 +1,376 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Pavel7004/WebShop/pkg/components"
+	"github.com/Pavel7004/WebShop/pkg/components/policy"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// Callers of the tests. The customer places order "order" and owns the
+// cart of the same ID, the seller owns item "item".
+const (
+	anonymous = ""
+	customer  = "customer"
+	other     = "other"
+	seller    = "seller"
+	staff     = "staff"
+	admin     = "admin"
+)
+
+var callers = []string{anonymous, customer, other, seller, staff, admin}
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(ctx context.Context, p *policy.Policy) error
+		allowed []string
+	}{
+		{
+			name: "GetUserById",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetUserById(ctx, customer)
+				return err
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "GetRecentlyAddedUsers",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetRecentlyAddedUsers(ctx, domain.PageRequest{})
+				return err
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "AddItem",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.AddItem(ctx, &domain.AddItemRequest{OwnerID: seller})
+				return err
+			},
+			allowed: []string{seller, admin},
+		},
+		{
+			name: "UpdateItem",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.UpdateItem(ctx, "item", &domain.UpdateItemRequest{})
+				return err
+			},
+			allowed: []string{seller, admin},
+		},
+		{
+			name: "CreateOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.CreateOrder(ctx, &domain.CreateOrderRequest{CustomerID: customer})
+				return err
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "GetOrderById",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetOrderById(ctx, "order")
+				return err
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "GetOrdersByCustomerId",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetOrdersByCustomerId(ctx, customer, domain.PageRequest{Limit: 10})
+				return err
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "PayOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.PayOrder(ctx, "order")
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "ShipOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.ShipOrder(ctx, "order")
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "ProcessOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.ProcessOrder(ctx, "order")
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "CancelOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.CancelOrder(ctx, "order")
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "RefundOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.RefundOrder(ctx, "order")
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "ExpireOrder",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.ExpireOrder(ctx, "order")
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "GetCart",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetCart(ctx, customer)
+				return err
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "AddToCart",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.AddToCart(ctx, customer, "item", 1)
+				return err
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "SetCartItem",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.SetCartItem(ctx, customer, "item", 1)
+				return err
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "RemoveFromCart",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.RemoveFromCart(ctx, customer, "item")
+				return err
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "ClearCart",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				return p.ClearCart(ctx, customer)
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "Checkout",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.Checkout(ctx, customer)
+				return err
+			},
+			allowed: []string{customer},
+		},
+		{
+			name: "TopUpBalance",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.TopUpBalance(ctx, customer, domain.NewMoney(100, domain.DefaultCurrency))
+				return err
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "WithdrawBalance",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.WithdrawBalance(ctx, customer, domain.NewMoney(100, domain.DefaultCurrency))
+				return err
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "GetTransactions",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GetTransactions(ctx, customer, domain.PageRequest{Limit: 10})
+				return err
+			},
+			allowed: []string{customer, staff, admin},
+		},
+		{
+			name: "RecomputeBalance",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.RecomputeBalance(ctx, customer)
+				return err
+			},
+			allowed: []string{staff, admin},
+		},
+		{
+			name: "GrantRole",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.GrantRole(ctx, customer, domain.RoleSeller)
+				return err
+			},
+			allowed: []string{admin},
+		},
+		{
+			name: "RevokeRole",
+			call: func(ctx context.Context, p *policy.Policy) error {
+				_, err := p.RevokeRole(ctx, seller, domain.RoleSeller)
+				return err
+			},
+			allowed: []string{admin},
+		},
+	}
+
+	p := policy.New(newFakeShop())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, caller := range callers {
+				ctx := context.Background()
+				if caller != anonymous {
+					ctx = domain.WithUserID(ctx, caller)
+				}
+
+				var want error
+				switch {
+				case caller == anonymous:
+					want = domain.ErrUnauthenticated
+				case !contains(tt.allowed, caller):
+					want = domain.ErrForbidden
+				}
+
+				if err := tt.call(ctx, p); !errors.Is(err, want) {
+					t.Errorf("caller %q: error = %v, want %v", caller, err, want)
+				}
+			}
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fakeShop knows the callers, one item and one order. Methods the policy
+// passes requests on to succeed, others panic on the nil Shop.
+type fakeShop struct {
+	components.Shop
+
+	users map[string]*domain.User
+}
+
+func newFakeShop() *fakeShop {
+	roles := map[string][]domain.Role{
+		customer: nil,
+		other:    nil,
+		seller:   {domain.RoleSeller},
+		staff:    {domain.RoleStaff},
+		admin:    {domain.RoleAdmin},
+	}
+
+	users := make(map[string]*domain.User, len(roles))
+	for id, r := range roles {
+		users[id] = &domain.User{ID: id, Roles: r}
+	}
+
+	return &fakeShop{users: users}
+}
+
+func (f *fakeShop) GetUserById(_ context.Context, id string) (*domain.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (f *fakeShop) GetItemById(context.Context, string) (*domain.Item, error) {
+	return &domain.Item{ID: "item", OwnerID: seller}, nil
+}
+
+func (f *fakeShop) GetOrderById(context.Context, string) (*domain.Order, error) {
+	return &domain.Order{ID: "order", CustomerID: customer}, nil
+}
+
+func (f *fakeShop) GetRecentlyAddedUsers(context.Context, domain.PageRequest) (*domain.UsersPage, error) {
+	return &domain.UsersPage{}, nil
+}
+
+func (f *fakeShop) AddItem(context.Context, *domain.AddItemRequest) (string, error) {
+	return "item", nil
+}
+
+func (f *fakeShop) UpdateItem(context.Context, string, *domain.UpdateItemRequest) (int64, error) {
+	return 1, nil
+}
+
+func (f *fakeShop) CreateOrder(context.Context, *domain.CreateOrderRequest) (string, error) {
+	return "order", nil
+}
+
+func (f *fakeShop) GetOrdersByCustomerId(context.Context, string, domain.PageRequest) (*domain.OrdersPage, error) {
+	return &domain.OrdersPage{}, nil
+}
+
+func (f *fakeShop) PayOrder(context.Context, string) error     { return nil }
+func (f *fakeShop) ShipOrder(context.Context, string) error    { return nil }
+func (f *fakeShop) ProcessOrder(context.Context, string) error { return nil }
+func (f *fakeShop) CancelOrder(context.Context, string) error  { return nil }
+func (f *fakeShop) RefundOrder(context.Context, string) error  { return nil }
+func (f *fakeShop) ExpireOrder(context.Context, string) error  { return nil }
+
+func (f *fakeShop) GetCart(context.Context, string) (*domain.CartView, error) {
+	return &domain.CartView{}, nil
+}
+
+func (f *fakeShop) AddToCart(context.Context, string, string, int64) (*domain.CartView, error) {
+	return &domain.CartView{}, nil
+}
+
+func (f *fakeShop) SetCartItem(context.Context, string, string, int64) (*domain.CartView, error) {
+	return &domain.CartView{}, nil
+}
+
+func (f *fakeShop) RemoveFromCart(context.Context, string, string) (*domain.CartView, error) {
+	return &domain.CartView{}, nil
+}
+
+func (f *fakeShop) ClearCart(context.Context, string) error { return nil }
+
+func (f *fakeShop) Checkout(context.Context, string) (string, error) {
+	return "order", nil
+}
+
+func (f *fakeShop) TopUpBalance(context.Context, string, domain.Money) (*domain.Transaction, error) {
+	return &domain.Transaction{}, nil
+}
+
+func (f *fakeShop) WithdrawBalance(context.Context, string, domain.Money) (*domain.Transaction, error) {
+	return &domain.Transaction{}, nil
+}
+
+func (f *fakeShop) GetTransactions(context.Context, string, domain.PageRequest) (*domain.TransactionsPage, error) {
+	return &domain.TransactionsPage{}, nil
+}
+
+func (f *fakeShop) RecomputeBalance(context.Context, string) (domain.Money, error) {
+	return domain.Money{}, nil
+}
+
+func (f *fakeShop) GrantRole(_ context.Context, userID string, _ domain.Role) (*domain.User, error) {
+	return f.users[userID], nil
+}
+
+func (f *fakeShop) RevokeRole(_ context.Context, userID string, _ domain.Role) (*domain.User, error) {
+	return f.users[userID], nil
+}
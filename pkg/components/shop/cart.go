@@ -0,0 +1,276 @@
+package shop
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func (s *Shop) GetCart(ctx context.Context, userID string) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	cart, err := s.loadCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.viewCart(ctx, cart)
+}
+
+func (s *Shop) AddToCart(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+	span.SetTag("quantity", quantity)
+
+	if quantity <= 0 {
+		return nil, domain.ErrCartQuantity
+	}
+
+	cart, err := s.loadCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.putCartItem(ctx, cart, itemID, cart.Quantity(itemID)+quantity); err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(ctx, userID)
+}
+
+// SetCartItem changes the quantity of an item already in the cart.
+func (s *Shop) SetCartItem(ctx context.Context, userID, itemID string, quantity int64) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+	span.SetTag("quantity", quantity)
+
+	cart, err := s.loadCart(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cart.Quantity(itemID) == 0 {
+		return nil, domain.ErrCartItemNotFound
+	}
+
+	if err := s.putCartItem(ctx, cart, itemID, quantity); err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(ctx, userID)
+}
+
+// RemoveFromCart removes the item from the cart. Items that no longer
+// exist can be removed too.
+func (s *Shop) RemoveFromCart(ctx context.Context, userID, itemID string) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("item_id", itemID)
+
+	if err := s.checkCartOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.RemoveCartItem(ctx, userID, itemID); err != nil {
+		return nil, err
+	}
+
+	return s.GetCart(ctx, userID)
+}
+
+func (s *Shop) ClearCart(ctx context.Context, userID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	if err := s.checkCartOwner(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.db.ClearCart(ctx, userID)
+}
+
+// Checkout creates an order the same way CreateOrder does. The cart is
+// emptied along with creating the order, and only if it didn't change
+// after it was read here; ErrCartChanged is returned otherwise, so the
+// client can look at the cart again and retry.
+func (s *Shop) Checkout(ctx context.Context, userID string) (string, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	cart, err := s.loadCart(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(cart.Items) == 0 {
+		return "", domain.ErrCartEmpty
+	}
+
+	span.SetTag("cart_version", cart.Version)
+
+	req := &domain.CreateOrderRequest{
+		CustomerID:  userID,
+		Items:       make([]domain.OrderItem, 0, len(cart.Items)),
+		CartVersion: cart.Version,
+	}
+	for _, it := range cart.Items {
+		req.Items = append(req.Items, domain.OrderItem{ID: it.ItemID, Quantity: it.Quantity})
+	}
+
+	return s.CreateOrder(ctx, req)
+}
+
+func (s *Shop) checkCartOwner(ctx context.Context, userID string) error {
+	_, err := s.db.GetUserById(ctx, userID)
+	return err
+}
+
+func (s *Shop) loadCart(ctx context.Context, userID string) (*domain.Cart, error) {
+	if err := s.checkCartOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.db.GetCart(ctx, userID)
+}
+
+// putCartItem sets the quantity of the item in the cart if the item is
+// in stock and priced in the same currency as the rest of the cart. The
+// quantity and the currency were worked out from the cart as it was read,
+// so it's changed only if nobody changed it since; ErrCartChanged is
+// returned otherwise.
+func (s *Shop) putCartItem(ctx context.Context, cart *domain.Cart, itemID string, quantity int64) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	if quantity <= 0 {
+		return domain.ErrCartQuantity
+	}
+
+	item, err := s.db.GetItemById(ctx, itemID)
+	if err != nil {
+		return err
+	}
+
+	if uint64(quantity) > item.Quantity {
+		return domain.NewInsufficientStockError([]string{itemID})
+	}
+
+	currency, err := s.cartCurrency(ctx, cart, itemID)
+	if err != nil {
+		return err
+	}
+
+	if currency != "" && currency != item.Price.Currency {
+		return domain.ErrCurrencyMismatch
+	}
+
+	return s.db.SetCartItem(ctx, cart.UserID, itemID, quantity, cart.Version)
+}
+
+// cartCurrency returns the currency of existing items in the cart other
+// than the excluded one, or an empty string if there are none.
+func (s *Shop) cartCurrency(ctx context.Context, cart *domain.Cart, exclude string) (string, error) {
+	for _, it := range cart.Items {
+		if it.ItemID == exclude {
+			continue
+		}
+
+		item, err := s.db.GetItemById(ctx, it.ItemID)
+		if errors.Is(err, domain.ErrItemNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return item.Price.Currency, nil
+	}
+
+	return "", nil
+}
+
+// viewCart prices the cart at current prices and checks every line
+// against the catalog. The total is in the currency of the first
+// existing item, lines in other currencies aren't counted.
+func (s *Shop) viewCart(ctx context.Context, cart *domain.Cart) (*domain.CartView, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	view := &domain.CartView{
+		UserID:    cart.UserID,
+		Lines:     make([]domain.CartLine, 0, len(cart.Items)),
+		Ready:     len(cart.Items) > 0,
+		UpdatedAt: cart.UpdatedAt,
+	}
+
+	var total domain.Money
+
+	for _, it := range cart.Items {
+		line := domain.CartLine{ItemID: it.ItemID, Quantity: it.Quantity}
+
+		item, err := s.db.GetItemById(ctx, it.ItemID)
+		switch {
+		case errors.Is(err, domain.ErrItemNotFound):
+			line.Problem = domain.CartProblemItemNotFound
+		case err != nil:
+			return nil, err
+		default:
+			line.Item = item
+
+			subtotal, err := item.Price.Mul(it.Quantity)
+			if err != nil {
+				line.Problem = domain.CartProblemAmountTooLarge
+				break
+			}
+			line.Subtotal = subtotal
+
+			if total.Currency == "" {
+				total.Currency = item.Price.Currency
+			}
+
+			if item.Price.Currency != total.Currency {
+				line.Problem = domain.CartProblemCurrencyMismatch
+				break
+			}
+
+			sum, err := total.Add(subtotal)
+			if err != nil {
+				line.Problem = domain.CartProblemAmountTooLarge
+				break
+			}
+			total = sum
+
+			if uint64(it.Quantity) > item.Quantity {
+				line.Problem = domain.CartProblemInsufficientStock
+			}
+		}
+
+		if line.Problem != "" {
+			view.Ready = false
+		}
+
+		view.Lines = append(view.Lines, line)
+	}
+
+	view.Total = domain.NewMoney(total.Amount, total.Currency)
+
+	return view, nil
+}
@@ -0,0 +1,71 @@
+package shop
+
+import (
+	"context"
+	"time"
+
+	"github.com/Pavel7004/Common/tracing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+// BeginIdempotent fails with ErrIdempotencyKeyReused if the key was used
+// with a request of another fingerprint, and with ErrIdempotencyKeyInUse
+// if the request with the key isn't finished yet.
+func (s *Shop) BeginIdempotent(ctx context.Context, key, fingerprint string) (*domain.IdempotentResponse, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", key)
+
+	if !domain.ValidIdempotencyKey(key) {
+		return nil, domain.ErrInvalidIdempotencyKey
+	}
+
+	stored, err := s.db.ClaimIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key:         scopedKey(ctx, key),
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(s.cfg.Idempotency.LockTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case stored == nil:
+		return nil, nil
+	case stored.Fingerprint != fingerprint:
+		return nil, domain.ErrIdempotencyKeyReused
+	case stored.Response == nil:
+		return nil, domain.ErrIdempotencyKeyInUse
+	}
+
+	span.SetTag("replayed", true)
+
+	return stored.Response, nil
+}
+
+func (s *Shop) FinishIdempotent(ctx context.Context, key, fingerprint string, resp *domain.IdempotentResponse) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("key", key)
+
+	if resp == nil {
+		return s.db.ReleaseIdempotencyKey(ctx, scopedKey(ctx, key))
+	}
+
+	return s.db.CompleteIdempotencyKey(ctx, &domain.IdempotencyRecord{
+		Key:         scopedKey(ctx, key),
+		Fingerprint: fingerprint,
+		Response:    resp,
+		ExpiresAt:   time.Now().Add(s.cfg.Idempotency.TTL),
+	})
+}
+
+// scopedKey prefixes the key with the ID of the caller, so keys made up
+// by different users never collide.
+func scopedKey(ctx context.Context, key string) string {
+	userID, _ := domain.UserIDFromContext(ctx)
+	return userID + ":" + key
+}
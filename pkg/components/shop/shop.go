@@ -2,23 +2,47 @@ package shop
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+
 	"github.com/Pavel7004/Common/tracing"
 	dbi "github.com/Pavel7004/WebShop/pkg/adapters/db"
 	"github.com/Pavel7004/WebShop/pkg/components"
 	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+	"github.com/Pavel7004/WebShop/pkg/infra/metrics"
+	"github.com/Pavel7004/WebShop/pkg/infra/token"
 )
 
 type Shop struct {
-	db dbi.DB
+	db     dbi.DB
+	cfg    *config.Config
+	tokens *token.Issuer
+
+	// dummyHash is checked against passwords of unknown emails, so
+	// login takes as long as for registered ones.
+	dummyHash []byte
 }
 
 var _ components.Shop = (*Shop)(nil)
 
-func New(db dbi.DB) *Shop {
+// expiryBatch is how many unpaid orders ExpireOrders reads at once.
+const expiryBatch = 100
+
+func New(db dbi.DB, cfg *config.Config) *Shop {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), cfg.Auth.BcryptCost)
+	if err != nil {
+		panic(err)
+	}
+
 	return &Shop{
-		db: db,
+		db:        db,
+		cfg:       cfg,
+		tokens:    token.New(&cfg.Auth),
+		dummyHash: dummyHash,
 	}
 }
 
@@ -37,35 +61,98 @@ func (s *Shop) AddItem(ctx context.Context, item *domain.AddItemRequest) (string
 
 	span.SetTag("item_request", item)
 
-	return s.db.AddItem(ctx, item)
+	if item != nil {
+		if err := s.checkUser(ctx, item.OwnerID, domain.ErrOwnerNotFound); err != nil {
+			return "", err
+		}
+	}
+
+	id, err := s.db.AddItem(ctx, item)
+	if err != nil {
+		return "", err
+	}
+
+	metrics.ItemsAdded.Inc()
+
+	return id, nil
 }
 
-func (s *Shop) GetItemsByPrice(ctx context.Context, from, to float64) ([]*domain.Item, error) {
+// FindItems returns a page of the catalog. The first page comes with
+// facet counts, next ones are only scrolled through and don't need them.
+// Prices are counted in the currency of the filter, or in the default
+// one if the filter doesn't restrict it.
+func (s *Shop) FindItems(ctx context.Context, filter domain.ItemFilter, page domain.PageRequest) (*domain.ItemsPage, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("from", from)
-	span.SetTag("to", to)
+	span.SetTag("sort", page.Sort)
+
+	result, err := s.db.FindItems(ctx, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.After != nil {
+		return result, nil
+	}
+
+	currency := filter.PriceCurrency()
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	result.Facets, err = s.db.CountItemFacets(ctx, filter, domain.NewPriceBuckets(currency))
+	if err != nil {
+		return nil, err
+	}
 
-	return s.db.GetItemsByPrice(ctx, from, to)
+	return result, nil
 }
 
-func (s *Shop) GetRecentlyAddedItems(ctx context.Context, period time.Duration) ([]*domain.Item, error) {
+func (s *Shop) GetRecentlyAddedItems(ctx context.Context, period time.Duration, page domain.PageRequest) (*domain.ItemsPage, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
 	span.SetTag("period", period.String())
 
-	return s.db.GetRecentlyAddedItems(ctx, period)
+	return s.db.FindItems(ctx, domain.ItemFilter{CreatedAfter: time.Now().Add(-period)}, page)
+}
+
+func (s *Shop) SearchItems(ctx context.Context, req domain.SearchRequest) (*domain.SearchResult, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("query", req.Query)
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	hits, err := s.db.SearchItems(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.SearchResult{Hits: hits}, nil
 }
 
 func (s *Shop) RegisterUser(ctx context.Context, user *domain.RegisterUserRequest) (string, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("user_request", *user)
+	span.SetTag("email", user.Email)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), s.cfg.Auth.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+
+	// Storages never see the password itself.
+	req := *user
+	req.Password = ""
+	req.PasswordHash = hash
 
-	return s.db.RegisterUser(ctx, user)
+	return s.db.RegisterUser(ctx, &req)
 }
 
 func (s *Shop) GetUserById(ctx context.Context, id string) (*domain.User, error) {
@@ -77,22 +164,126 @@ func (s *Shop) GetUserById(ctx context.Context, id string) (*domain.User, error)
 	return s.db.GetUserById(ctx, id)
 }
 
-func (s *Shop) GetItemsByOwnerId(ctx context.Context, id string) ([]*domain.Item, error) {
+func (s *Shop) GetItemsByOwnerId(ctx context.Context, id string, page domain.PageRequest) (*domain.ItemsPage, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
 	span.SetTag("id", id)
 
-	return s.db.GetItemsByOwnerId(ctx, id)
+	return s.db.FindItems(ctx, domain.ItemFilter{OwnerID: id}, page)
+}
+
+func (s *Shop) GetRecentlyAddedUsers(ctx context.Context, page domain.PageRequest) (*domain.UsersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("limit", page.Limit)
+
+	return s.db.FindUsers(ctx, page)
+}
+
+// GrantRole gives the role to the user and returns the updated user.
+func (s *Shop) GrantRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("role", role)
+
+	if _, err := domain.ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.AddUserRole(ctx, userID, role); err != nil {
+		return nil, err
+	}
+
+	return s.db.GetUserById(ctx, userID)
+}
+
+// RevokeRole takes the role from the user and returns the updated user.
+func (s *Shop) RevokeRole(ctx context.Context, userID string, role domain.Role) (*domain.User, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("role", role)
+
+	if _, err := domain.ParseRole(string(role)); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.RemoveUserRole(ctx, userID, role); err != nil {
+		return nil, err
+	}
+
+	return s.db.GetUserById(ctx, userID)
+}
+
+// Login checks the password of the user with the email and issues a
+// token pair. Unknown emails and wrong passwords are reported the same
+// way, so emails of users can't be probed.
+func (s *Shop) Login(ctx context.Context, req *domain.LoginRequest) (*domain.TokenPair, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("email", req.Email)
+
+	creds, err := s.db.GetUserCredentials(ctx, req.Email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+
+	hash := s.dummyHash
+	if creds != nil {
+		hash = creds.PasswordHash
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil || creds == nil {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	span.SetTag("user_id", creds.UserID)
+
+	return s.tokens.Issue(creds.UserID)
 }
 
-func (s *Shop) GetRecentlyAddedUsers(ctx context.Context, count int64) ([]*domain.User, error) {
+// Refresh exchanges a refresh token for a new token pair. Tokens of
+// users that no longer exist are rejected.
+func (s *Shop) Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("count", count)
+	userID, err := s.tokens.Verify(refreshToken, domain.TokenRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetTag("user_id", userID)
+
+	if _, err := s.db.GetUserById(ctx, userID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, domain.ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	return s.tokens.Issue(userID)
+}
+
+func (s *Shop) Authenticate(ctx context.Context, accessToken string) (string, error) {
+	span, _ := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	userID, err := s.tokens.Verify(accessToken, domain.TokenAccess)
+	if err != nil {
+		return "", err
+	}
+
+	span.SetTag("user_id", userID)
 
-	return s.db.GetRecentlyAddedUsers(ctx, count)
+	return userID, nil
 }
 
 func (s *Shop) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemRequest) (int64, error) {
@@ -101,6 +292,12 @@ func (s *Shop) UpdateItem(ctx context.Context, id string, in *domain.UpdateItemR
 
 	span.SetTag("id", id)
 
+	if in != nil && in.OwnerID != nil {
+		if err := s.checkUser(ctx, *in.OwnerID, domain.ErrOwnerNotFound); err != nil {
+			return 0, err
+		}
+	}
+
 	return s.db.UpdateItem(ctx, id, in)
 }
 
@@ -108,31 +305,180 @@ func (s *Shop) CreateOrder(ctx context.Context, req *domain.CreateOrderRequest)
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	return s.db.CreateOrder(ctx, req)
+	if req == nil {
+		return "", domain.ErrNoOrder
+	}
+
+	span.SetTag("customer_id", req.CustomerID)
+
+	if err := s.checkUser(ctx, req.CustomerID, domain.ErrCustomerNotFound); err != nil {
+		return "", err
+	}
+
+	if err := s.checkItems(ctx, req.Items); err != nil {
+		return "", err
+	}
+
+	id, err := s.db.CreateOrder(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	metrics.Orders.WithLabelValues(string(domain.CREATED)).Inc()
+
+	return id, nil
+}
+
+// checkUser makes sure that the user referenced by a request exists. If
+// it doesn't, notFound is returned with the user ID.
+func (s *Shop) checkUser(ctx context.Context, id string, notFound error) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", id)
+
+	_, err := s.db.GetUserById(ctx, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return domain.NewMissingReferencesError(notFound, []string{id})
+	}
+
+	return err
+}
+
+// checkItems makes sure that all ordered items exist. Storages would
+// report missing items as out of stock otherwise.
+func (s *Shop) checkItems(ctx context.Context, lines []domain.OrderItem) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	var missing []string
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		if seen[line.ID] {
+			continue
+		}
+		seen[line.ID] = true
+
+		_, err := s.db.GetItemById(ctx, line.ID)
+		if errors.Is(err, domain.ErrItemNotFound) {
+			missing = append(missing, line.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		span.SetTag("missing_items", missing)
+		return domain.NewMissingReferencesError(domain.ErrOrderItemsNotFound, missing)
+	}
+
+	return nil
+}
+
+func (s *Shop) GetOrderById(ctx context.Context, id string) (*domain.Order, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("id", id)
+
+	return s.db.GetOrderInfo(ctx, id)
+}
+
+func (s *Shop) GetOrdersByCustomerId(ctx context.Context, id string, page domain.PageRequest) (*domain.OrdersPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("id", id)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if page.Limit <= 0 {
+		return nil, domain.ErrInvalidPagination
+	}
+
+	return s.db.GetOrdersByCustomerId(ctx, id, page)
 }
 
 func (s *Shop) PayOrder(ctx context.Context, orderID string) error {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
-	span.SetTag("orderID", orderID)
+	span.SetTag("order_id", orderID)
 
-	err := s.db.UpdateOrder(ctx, orderID, domain.UpdateOrderRequest{
-		Status: &domain.PAID,
-	})
+	order, err := s.db.GetOrderInfo(ctx, orderID)
 	if err != nil {
 		return err
 	}
 
+	span.SetTag("order_status", string(order.Status))
+
+	if order.Status == domain.CREATED && time.Since(order.CreatedAt) > s.cfg.OrderPaymentTTL {
+		if err := s.transitOrder(ctx, order, domain.EXPIRED, domain.ActorSystem); err != nil {
+			return err
+		}
+
+		return domain.ErrOrderExpired
+	}
+
+	if err := order.Status.TransitionTo(domain.PAID); err != nil {
+		return err
+	}
+
+	err = s.db.PayOrder(ctx, orderID, domain.NewStatusTransition(order.Status, domain.PAID, domain.ActorCustomer))
+	if err != nil {
+		return err
+	}
+
+	metrics.Orders.WithLabelValues(string(domain.PAID)).Inc()
+	metrics.Revenue.WithLabelValues(order.Total.Currency).Add(order.Total.Float())
+
 	return nil
 }
 
+func (s *Shop) ShipOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", orderID)
+
+	return s.changeOrderStatus(ctx, orderID, domain.SHIPPED, domain.ActorStaff)
+}
+
 func (s *Shop) ProcessOrder(ctx context.Context, orderID string) error {
 	span, ctx := tracing.StartSpanFromContext(ctx)
 	defer span.Finish()
 
 	span.SetTag("order_id", orderID)
 
+	return s.changeOrderStatus(ctx, orderID, domain.DELIVERED, domain.ActorStaff)
+}
+
+// CancelOrder records who cancelled the order: its customer, or staff
+// cancelling it for them.
+func (s *Shop) CancelOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", orderID)
+
+	order, err := s.db.GetOrderInfo(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	span.SetTag("order_status", string(order.Status))
+
+	return s.transitOrder(ctx, order, domain.CANCELLED, cancelActor(ctx, order))
+}
+
+func (s *Shop) RefundOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", orderID)
+
 	order, err := s.db.GetOrderInfo(ctx, orderID)
 	if err != nil {
 		return err
@@ -140,19 +486,222 @@ func (s *Shop) ProcessOrder(ctx context.Context, orderID string) error {
 
 	span.SetTag("order_status", string(order.Status))
 
-	switch order.Status {
-	case domain.CREATED:
-		return domain.ErrOrderNotPaid
-	case domain.DELIVERED:
-		return domain.ErrOrderAlreadyDelivered
+	if err := order.Status.TransitionTo(domain.REFUNDED); err != nil {
+		return err
 	}
 
-	err = s.db.UpdateOrder(ctx, orderID, domain.UpdateOrderRequest{
-		Status: &domain.DELIVERED,
-	})
+	err = s.db.RefundOrder(ctx, orderID, domain.NewStatusTransition(order.Status, domain.REFUNDED, domain.ActorStaff))
+	if err != nil {
+		return err
+	}
+
+	metrics.Orders.WithLabelValues(string(domain.REFUNDED)).Inc()
+	metrics.Refunds.WithLabelValues(order.Total.Currency).Add(order.Total.Float())
+
+	return nil
+}
+
+func (s *Shop) ExpireOrder(ctx context.Context, orderID string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("order_id", orderID)
+
+	return s.changeOrderStatus(ctx, orderID, domain.EXPIRED, domain.ActorSystem)
+}
+
+// ExpireOrders expires orders left unpaid for longer than the payment
+// TTL, oldest first, and returns how many of them it expired. Orders
+// paid or cancelled in the meantime are skipped.
+func (s *Shop) ExpireOrders(ctx context.Context) (int, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	expired := 0
+	for {
+		ids, err := s.db.GetUnpaidOrderIds(ctx, time.Now().Add(-s.cfg.OrderPaymentTTL), expiryBatch)
+		if err != nil {
+			return expired, err
+		}
+
+		batch := 0
+		for _, id := range ids {
+			err := s.ExpireOrder(ctx, id)
+			if err != nil {
+				var domErr *domain.Error
+				if errors.As(err, &domErr) {
+					continue
+				}
+
+				return expired, err
+			}
+
+			batch++
+		}
+
+		expired += batch
+		span.SetTag("expired", expired)
+
+		// A batch that expired nothing would come back the same.
+		if len(ids) < expiryBatch || batch == 0 {
+			return expired, nil
+		}
+	}
+}
+
+// ExpireOrdersEvery runs ExpireOrders every interval until ctx is done.
+// Expired idempotency records are purged along the way.
+func (s *Shop) ExpireOrdersEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		expired, err := s.ExpireOrders(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to expire unpaid orders")
+		}
+
+		if expired > 0 {
+			log.Info().Int("count", expired).Msg("Expired unpaid orders")
+		}
+
+		if err := s.db.PurgeIdempotencyKeys(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to purge expired idempotency keys")
+		}
+	}
+}
+
+// cancelActor tells the customer of the order from other callers, whom
+// the policy lets cancel it only as staff. Calls without a caller are
+// made by the shop itself.
+func cancelActor(ctx context.Context, order *domain.Order) string {
+	id, ok := domain.UserIDFromContext(ctx)
+	switch {
+	case !ok:
+		return domain.ActorSystem
+	case id == order.CustomerID:
+		return domain.ActorCustomer
+	default:
+		return domain.ActorStaff
+	}
+}
+
+func (s *Shop) changeOrderStatus(ctx context.Context, orderID string, to domain.StatusID, actor string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	order, err := s.db.GetOrderInfo(ctx, orderID)
 	if err != nil {
 		return err
 	}
 
+	span.SetTag("order_status", string(order.Status))
+
+	return s.transitOrder(ctx, order, to, actor)
+}
+
+func (s *Shop) transitOrder(ctx context.Context, order *domain.Order, to domain.StatusID, actor string) error {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("from", string(order.Status))
+	span.SetTag("to", string(to))
+
+	if err := order.Status.TransitionTo(to); err != nil {
+		return err
+	}
+
+	if err := s.db.TransitionOrder(ctx, order.ID, domain.NewStatusTransition(order.Status, to, actor)); err != nil {
+		return err
+	}
+
+	metrics.Orders.WithLabelValues(string(to)).Inc()
+
 	return nil
 }
+
+func (s *Shop) TopUpBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("amount", amount.String())
+
+	if !amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	return s.db.PostTransaction(ctx, &domain.PostTransactionRequest{
+		UserID: userID,
+		Kind:   domain.TOPUP,
+		Amount: amount,
+	})
+}
+
+func (s *Shop) WithdrawBalance(ctx context.Context, userID string, amount domain.Money) (*domain.Transaction, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("amount", amount.String())
+
+	if !amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	return s.db.Withdraw(ctx, userID, amount)
+}
+
+func (s *Shop) GetTransactions(ctx context.Context, userID string, page domain.PageRequest) (*domain.TransactionsPage, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+	span.SetTag("sort", page.Sort)
+	span.SetTag("limit", page.Limit)
+
+	if page.Limit <= 0 {
+		return nil, domain.ErrInvalidPagination
+	}
+
+	return s.db.GetTransactions(ctx, userID, page)
+}
+
+func (s *Shop) RecomputeBalance(ctx context.Context, userID string) (domain.Money, error) {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	span.SetTag("user_id", userID)
+
+	return s.db.RecomputeBalance(ctx, userID)
+}
+
+func (s *Shop) CheckHealth(ctx context.Context) *domain.HealthReport {
+	span, ctx := tracing.StartSpanFromContext(ctx)
+	defer span.Finish()
+
+	report := &domain.HealthReport{
+		Status:       domain.HealthOK,
+		Dependencies: map[string]domain.DependencyHealth{},
+	}
+
+	dep := domain.DependencyHealth{Status: domain.HealthOK}
+	if err := s.db.Ping(ctx); err != nil {
+		log.Warn().Err(err).Str("dependency", s.cfg.DBDriver).Msg("Dependency is unavailable")
+
+		dep = domain.DependencyHealth{Status: domain.HealthUnavailable}
+		report.Status = domain.HealthUnavailable
+	}
+
+	report.Dependencies[s.cfg.DBDriver] = dep
+
+	span.SetTag("status", string(report.Status))
+
+	return report
+}
@@ -0,0 +1,75 @@
+package shop_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Pavel7004/WebShop/pkg/adapters/db/memory"
+	"github.com/Pavel7004/WebShop/pkg/components/shop"
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+func TestCancelOrderActor(t *testing.T) {
+	ctx := context.Background()
+	db := memory.New()
+	s := shop.New(db, &config.Config{})
+
+	register := func(name string) string {
+		id, err := db.RegisterUser(ctx, &domain.RegisterUserRequest{Name: name, Email: name + "@example.com", Phone: "123"})
+		if err != nil {
+			t.Fatalf("RegisterUser() error = %v", err)
+		}
+
+		return id
+	}
+
+	customer, staff := register("customer"), register("staff")
+
+	item, err := db.AddItem(ctx, &domain.AddItemRequest{
+		OwnerID:  register("seller"),
+		Name:     "item",
+		Category: "misc",
+		Price:    domain.NewMoney(100, domain.DefaultCurrency),
+		Quantity: 10,
+	})
+	if err != nil {
+		t.Fatalf("AddItem() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		actor string
+	}{
+		{"Customer", domain.WithUserID(ctx, customer), domain.ActorCustomer},
+		{"Staff", domain.WithUserID(ctx, staff), domain.ActorStaff},
+		{"NoCaller", ctx, domain.ActorSystem},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := db.CreateOrder(ctx, &domain.CreateOrderRequest{
+				CustomerID: customer,
+				Items:      []domain.OrderItem{{ID: item, Quantity: 1}},
+			})
+			if err != nil {
+				t.Fatalf("CreateOrder() error = %v", err)
+			}
+
+			if err := s.CancelOrder(tt.ctx, id); err != nil {
+				t.Fatalf("CancelOrder() error = %v", err)
+			}
+
+			order, err := db.GetOrderInfo(ctx, id)
+			if err != nil {
+				t.Fatalf("GetOrderInfo() error = %v", err)
+			}
+
+			last := order.Transitions[len(order.Transitions)-1]
+			if last.To != domain.CANCELLED || last.Actor != tt.actor {
+				t.Errorf("last transition = %+v, want to %s by %s", last, domain.CANCELLED, tt.actor)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+)
+
+// MaxPasswordBytes is the longest password bcrypt can hash.
+const MaxPasswordBytes = 72
+
+// TokenKind tells access tokens, which authenticate requests, from
+// refresh tokens, which are only exchanged for new token pairs.
+type TokenKind string
+
+const (
+	TokenAccess  TokenKind = "access"
+	TokenRefresh TokenKind = "refresh"
+)
+
+// Credentials are what a user logs in with: the password hash stored
+// for the email.
+type Credentials struct {
+	UserID       string
+	PasswordHash []byte
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required,email,max=254"`
+	Password string `json:"password" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair is issued on login and refresh. ExpiresIn is the lifetime
+// of the access token in seconds.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"`
+}
+
+type userIDKey struct{}
+
+// WithUserID returns a copy of ctx carrying the ID of the authenticated
+// user.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext returns the ID of the user the request is made by,
+// if it's authenticated.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(userIDKey{}).(string)
+	return id, ok && id != ""
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"time"
+)
+
+// Problems of cart lines that keep a cart from being checked out.
+const (
+	CartProblemItemNotFound      = "item_not_found"
+	CartProblemInsufficientStock = "insufficient_stock"
+	CartProblemCurrencyMismatch  = "currency_mismatch"
+	CartProblemAmountTooLarge    = "amount_too_large"
+)
+
+// CartItem is a line of a cart as it's stored: only what and how many.
+// Prices and stock are looked up when the cart is shown.
+type CartItem struct {
+	ItemID   string    `json:"item_id"`
+	Quantity int64     `json:"quantity"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Cart is a stored cart of a user, lines are in the order they were
+// added. Version grows with every change, so checkout can tell whether
+// the cart changed after it was read.
+type Cart struct {
+	UserID    string
+	Items     []CartItem
+	Version   int64
+	UpdatedAt time.Time
+}
+
+// CartLine is a cart line with the current state of its item. Item is
+// nil if it no longer exists. Problem is set if the line can't be
+// ordered as it is.
+type CartLine struct {
+	ItemID   string `json:"item_id"`
+	Quantity int64  `json:"quantity"`
+	Item     *Item  `json:"item,omitempty"`
+	Subtotal Money  `json:"subtotal"`
+	Problem  string `json:"problem,omitempty" enums:"item_not_found,insufficient_stock,currency_mismatch,amount_too_large"`
+}
+
+// CartView is a cart priced at current prices. Ready tells whether it
+// can be checked out: it isn't empty and no line has a problem.
+type CartView struct {
+	UserID    string     `json:"user_id"`
+	Lines     []CartLine `json:"lines"`
+	Total     Money      `json:"total"`
+	Ready     bool       `json:"ready"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type AddToCartRequest struct {
+	ItemID   string `json:"item_id" binding:"required,id"`
+	Quantity int64  `json:"quantity" binding:"gt=0"`
+}
+
+type SetCartQuantityRequest struct {
+	Quantity int64 `json:"quantity" binding:"gt=0"`
+}
+
+// Quantity returns how many of the item the cart holds.
+func (c *Cart) Quantity(itemID string) int64 {
+	for _, it := range c.Items {
+		if it.ItemID == itemID {
+			return it.Quantity
+		}
+	}
+
+	return 0
+}
@@ -1,33 +1,130 @@
 package domain
 
+// Category tells what kind of failure an error is, so adapters can
+// report it properly, e.g. pick an HTTP status.
+type Category string
+
 var (
-	ErrItemNotFound          = NewError(404, "item_not_found", "Item not found")
-	ErrInvalidId             = NewError(404, "invalid_id", "Can't parse id string")
-	ErrNoItem                = NewError(404, "item_is_nil", "Got nil item")
-	ErrUserNotFound          = NewError(404, "user_not_found", "User not found")
-	ErrNoUpdate              = NewError(404, "update_not_specified", "There are no updates")
-	ErrOrderNotProcessed     = NewError(404, "order_not_processed", "Order not processed")
-	ErrOrderNotFound         = NewError(404, "order_not_found", "Order not found")
-	ErrNoOrder               = NewError(404, "order_not_provided", "Order is nil")
-	ErrOrderNotPaid          = NewError(404, "order_not_paid", "Order isn't paid")
-	ErrOrderAlreadyDelivered = NewError(404, "order_delivered", "Order already delivered")
-	ErrOrderQuantity         = NewError(400, "order_quantity_invalid", "Order items quantity can't be negative or zero")
+	// CategoryValidation means the request itself is invalid.
+	CategoryValidation Category = "validation"
+	// CategoryUnauthenticated means the caller isn't known: credentials
+	// or a token are missing or wrong.
+	CategoryUnauthenticated Category = "unauthenticated"
+	// CategoryForbidden means the caller is known but isn't allowed to
+	// do what it asks.
+	CategoryForbidden Category = "forbidden"
+	// CategoryNotFound means a referenced entity doesn't exist.
+	CategoryNotFound Category = "not_found"
+	// CategoryConflict means the request conflicts with the current
+	// state of an entity, e.g. an order status.
+	CategoryConflict Category = "conflict"
+	// CategoryPrecondition means the request is valid but business rules
+	// don't allow it, e.g. there is not enough money.
+	CategoryPrecondition Category = "precondition"
+	// CategoryInternal means the failure isn't caused by the request.
+	CategoryInternal Category = "internal"
+)
+
+var (
+	ErrItemNotFound           = NewError(CategoryNotFound, "item_not_found", "Item not found")
+	ErrInvalidId              = NewError(CategoryValidation, "invalid_id", "Can't parse id string")
+	ErrNoItem                 = NewError(CategoryValidation, "item_is_nil", "Got nil item")
+	ErrUserNotFound           = NewError(CategoryNotFound, "user_not_found", "User not found")
+	ErrNoUpdate               = NewError(CategoryValidation, "update_not_specified", "There are no updates")
+	ErrOrderNotFound          = NewError(CategoryNotFound, "order_not_found", "Order not found")
+	ErrNoOrder                = NewError(CategoryValidation, "order_not_provided", "Order is nil")
+	ErrOrderNotPaid           = NewError(CategoryPrecondition, "order_not_paid", "Order isn't paid")
+	ErrOrderAlreadyDelivered  = NewError(CategoryConflict, "order_delivered", "Order already delivered")
+	ErrOrderQuantity          = NewError(CategoryValidation, "order_quantity_invalid", "Order items quantity can't be negative or zero")
+	ErrOrderStatusUnchanged   = NewError(CategoryConflict, "order_status_unchanged", "Order already has this status")
+	ErrOrderCancelled         = NewError(CategoryConflict, "order_cancelled", "Order is cancelled")
+	ErrOrderRefunded          = NewError(CategoryConflict, "order_refunded", "Order is refunded")
+	ErrOrderExpired           = NewError(CategoryConflict, "order_expired", "Order is expired")
+	ErrOrderInvalidTransition = NewError(CategoryConflict, "order_invalid_transition", "Order can't move to requested status")
+	ErrOrderStatusConflict    = NewError(CategoryConflict, "order_status_conflict", "Order status was changed by another request")
+	ErrOwnerNotFound          = NewError(CategoryPrecondition, "owner_not_found", "Item owner doesn't exist")
+	ErrCustomerNotFound       = NewError(CategoryPrecondition, "customer_not_found", "Order customer doesn't exist")
+	ErrOrderItemsNotFound     = NewError(CategoryPrecondition, "order_items_not_found", "Ordered items don't exist")
+	ErrCartItemNotFound       = NewError(CategoryNotFound, "cart_item_not_found", "Item isn't in the cart")
+	ErrCartQuantity           = NewError(CategoryValidation, "cart_quantity_invalid", "Cart item quantity must be positive")
+	ErrCartEmpty              = NewError(CategoryPrecondition, "cart_empty", "Cart is empty")
+	ErrCartChanged            = NewError(CategoryConflict, "cart_changed", "Cart was changed by another request")
+	ErrInsufficientStock      = NewError(CategoryPrecondition, "insufficient_stock", "Not enough items in stock")
+	ErrInsufficientFunds      = NewError(CategoryPrecondition, "insufficient_funds", "Not enough money on balance")
+	ErrInvalidAmount          = NewError(CategoryValidation, "amount_invalid", "Amount must be positive")
+	ErrInvalidMoney           = NewError(CategoryValidation, "money_invalid", "Can't parse money amount")
+	ErrMoneyOverflow          = NewError(CategoryValidation, "money_overflow", "Amount of money is too large")
+	ErrInvalidCurrency        = NewError(CategoryValidation, "currency_invalid", "Currency must be a three letter ISO 4217 code")
+	ErrCurrencyMismatch       = NewError(CategoryPrecondition, "currency_mismatch", "Money currencies don't match")
+	ErrInvalidPagination      = NewError(CategoryValidation, "pagination_invalid", "Offset can't be negative and limit must be positive")
+	ErrInvalidCursor          = NewError(CategoryValidation, "cursor_invalid", "Cursor is malformed or was issued for another sort order")
+	ErrInvalidSort            = NewError(CategoryValidation, "sort_invalid", "Unsupported sort order")
+	ErrInvalidSearchQuery     = NewError(CategoryValidation, "search_query_invalid", "Search query must have words and be at most 256 bytes long")
+	ErrEmailTaken             = NewError(CategoryConflict, "email_taken", "User with this email already exists")
+	ErrInvalidCredentials     = NewError(CategoryUnauthenticated, "credentials_invalid", "Wrong email or password")
+	ErrUnauthenticated        = NewError(CategoryUnauthenticated, "unauthenticated", "Authentication required")
+	ErrInvalidToken           = NewError(CategoryUnauthenticated, "token_invalid", "Token is invalid")
+	ErrTokenExpired           = NewError(CategoryUnauthenticated, "token_expired", "Token is expired")
+	ErrForbidden              = NewError(CategoryForbidden, "forbidden", "Not allowed to do this")
+	ErrInvalidRole            = NewError(CategoryValidation, "role_invalid", "Unknown role")
+	ErrInvalidIdempotencyKey  = NewError(CategoryValidation, "idempotency_key_invalid", "Idempotency key must be 1 to 255 printable ASCII characters")
+	ErrIdempotencyKeyReused   = NewError(CategoryPrecondition, "idempotency_key_reused", "Idempotency key was used with another request")
+	ErrIdempotencyKeyInUse    = NewError(CategoryConflict, "idempotency_key_in_use", "Request with this idempotency key is still being processed")
+	ErrValidation             = NewError(CategoryValidation, "validation_failed", "Request has invalid fields")
+	ErrMalformedRequest       = NewError(CategoryValidation, "request_malformed", "Can't parse request body")
+	ErrInternal               = NewError(CategoryInternal, "internal_error", "Internal error")
 )
 
 type Error struct {
-	CodeHTTP int    `json:"-"`
-	Code     string `json:"code,omitempty"`
-	Message  string `json:"message"`
+	Category Category    `json:"-"`
+	Code     string      `json:"code,omitempty"`
+	Message  string      `json:"message"`
+	Details  interface{} `json:"details,omitempty"`
 }
 
 func (err *Error) Error() string {
 	return err.Message
 }
 
-func NewError(httpCode int, code, message string) error {
+// Is reports errors with the same code as equal, so errors.Is matches
+// copies carrying details against the sentinel they were made from.
+func (err *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return err.Code == t.Code
+}
+
+func NewError(category Category, code, message string) error {
 	return &Error{
-		CodeHTTP: httpCode,
+		Category: category,
 		Code:     code,
 		Message:  message,
 	}
 }
+
+// WithDetails returns a copy of err with details attached.
+// Errors that aren't *Error are returned unchanged.
+func WithDetails(err error, details interface{}) error {
+	e, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+
+	cp := *e
+	cp.Details = details
+
+	return &cp
+}
+
+// NewMissingReferencesError lists IDs of records that the request refers
+// to but that don't exist.
+func NewMissingReferencesError(err error, ids []string) error {
+	return WithDetails(err, map[string][]string{"missing_ids": ids})
+}
+
+func NewInsufficientStockError(itemIDs []string) error {
+	return WithDetails(ErrInsufficientStock, map[string][]string{"item_ids": itemIDs})
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"sort"
+)
+
+// DefaultPriceBounds are lower bounds of price facet buckets in minor
+// units: 0-10, 10-50, 50-100, 100-500, 500-1000 and 1000+.
+var DefaultPriceBounds = []int64{0, 10_00, 50_00, 100_00, 500_00, 1000_00}
+
+// PriceBuckets splits prices in the currency into ranges from one bound
+// up to the next one, exclusive. The last range has no upper bound.
+type PriceBuckets struct {
+	Currency string
+	Bounds   []int64
+}
+
+func NewPriceBuckets(currency string) PriceBuckets {
+	return PriceBuckets{
+		Currency: currency,
+		Bounds:   DefaultPriceBounds,
+	}
+}
+
+// Bucket returns the index of the range the amount falls in, or -1 if
+// it's below all of them.
+func (b *PriceBuckets) Bucket(amount int64) int {
+	for i := len(b.Bounds) - 1; i >= 0; i-- {
+		if amount >= b.Bounds[i] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ItemFacets count items matching a filter per category and per price
+// range. Every facet ignores its own part of the filter, so the counts
+// show what choosing another category or price range would give.
+type ItemFacets struct {
+	Categories []CategoryCount `json:"categories"`
+	Prices     []PriceCount    `json:"prices"`
+}
+
+type CategoryCount struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+type PriceCount struct {
+	From  Money  `json:"from"`
+	To    *Money `json:"to,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// CategoryFacetFilter returns the filter category counts are made with.
+func (f ItemFilter) CategoryFacetFilter() ItemFilter {
+	f.Categories = nil
+	return f
+}
+
+// PriceFacetFilter returns the filter price counts are made with. Only
+// items in the currency of the buckets are counted.
+func (f ItemFilter) PriceFacetFilter(buckets PriceBuckets) ItemFilter {
+	f.MinPrice, f.MaxPrice = nil, nil
+	f.Currency = buckets.Currency
+	return f
+}
+
+// NewItemFacets builds facets from item counts per category and per
+// price bucket index. Categories go from the most popular one, all
+// price ranges are listed even if they are empty.
+func NewItemFacets(categories map[string]int64, prices []int64, buckets PriceBuckets) *ItemFacets {
+	facets := &ItemFacets{
+		Categories: make([]CategoryCount, 0, len(categories)),
+		Prices:     make([]PriceCount, 0, len(buckets.Bounds)),
+	}
+
+	for category, count := range categories {
+		facets.Categories = append(facets.Categories, CategoryCount{Category: category, Count: count})
+	}
+
+	sort.Slice(facets.Categories, func(i, j int) bool {
+		a, b := facets.Categories[i], facets.Categories[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+
+		return a.Category < b.Category
+	})
+
+	for i, bound := range buckets.Bounds {
+		count := PriceCount{From: NewMoney(bound, buckets.Currency)}
+		if i+1 < len(buckets.Bounds) {
+			to := NewMoney(buckets.Bounds[i+1], buckets.Currency)
+			count.To = &to
+		}
+		if i < len(prices) {
+			count.Count = prices[i]
+		}
+
+		facets.Prices = append(facets.Prices, count)
+	}
+
+	return facets
+}
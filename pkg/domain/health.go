@@ -0,0 +1,23 @@
+package domain
+
+type HealthStatus string
+
+var (
+	HealthOK          HealthStatus = "ok"
+	HealthUnavailable HealthStatus = "unavailable"
+	HealthDraining    HealthStatus = "draining"
+)
+
+// DependencyHealth is the status of a dependency. Why it's unavailable
+// is logged only, probes are often public and errors of drivers name
+// hosts and users.
+type DependencyHealth struct {
+	Status HealthStatus `json:"status"`
+}
+
+// HealthReport is the readiness of the service. Status is ok only when
+// every dependency is ok.
+type HealthReport struct {
+	Status       HealthStatus                `json:"status"`
+	Dependencies map[string]DependencyHealth `json:"dependencies,omitempty"`
+}
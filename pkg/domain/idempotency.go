@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+)
+
+// MaxIdempotencyKeyLength limits idempotency keys in bytes.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is a request made with an idempotency key. Key is
+// scoped to the user who made the request, Fingerprint tells requests
+// made with the same key apart. Response is nil while the request is
+// being processed.
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Response    *IdempotentResponse
+	ExpiresAt   time.Time
+}
+
+// IdempotentResponse is a stored response that is replayed when a
+// request is retried with the same key.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// ValidIdempotencyKey reports whether the key is 1 to 255 printable
+// ASCII characters.
+func ValidIdempotencyKey(key string) bool {
+	if key == "" || len(key) > MaxIdempotencyKeyLength {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] < ' ' || key[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -10,25 +10,26 @@ type Item struct {
 	Name        string    `json:"name"`
 	Description string    `json:"desc"`
 	Category    string    `json:"category"`
-	Price       float64   `json:"price"`
+	Price       Money     `json:"price"`
 	CreatedAt   time.Time `json:"created_at"`
 	Quantity    uint64    `json:"quantity"`
 }
 
+// Money fields are validated by their amount in minor units.
 type AddItemRequest struct {
-	OwnerID     string  `json:"owner_id"`
-	Name        string  `json:"name"`
-	Description string  `json:"desc"`
-	Category    string  `json:"category"`
-	Price       float64 `json:"price"`
-	Quantity    uint64  `json:"quantity"`
+	OwnerID     string `json:"owner_id" binding:"required,id"`
+	Name        string `json:"name" binding:"required,max=200"`
+	Description string `json:"desc" binding:"max=5000"`
+	Category    string `json:"category" binding:"required,max=100"`
+	Price       Money  `json:"price" binding:"gt=0"`
+	Quantity    uint64 `json:"quantity"`
 }
 
 type UpdateItemRequest struct {
-	OwnerID     *string  `json:"owner_id"`
-	Name        *string  `json:"name"`
-	Description *string  `json:"desc"`
-	Category    *string  `json:"category"`
-	Price       *float64 `json:"price"`
-	Quantity    *uint64  `json:"quantity"`
+	OwnerID     *string `json:"owner_id" binding:"omitempty,id"`
+	Name        *string `json:"name" binding:"omitempty,min=1,max=200"`
+	Description *string `json:"desc" binding:"omitempty,max=5000"`
+	Category    *string `json:"category" binding:"omitempty,min=1,max=100"`
+	Price       *Money  `json:"price" binding:"omitempty,gt=0"`
+	Quantity    *uint64 `json:"quantity"`
 }
@@ -0,0 +1,250 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a request doesn't specify one.
+const DefaultCurrency = "RUB"
+
+// minorDigits is the number of digits after the decimal point. Every
+// supported currency has 100 minor units in a major one.
+const (
+	minorDigits = 2
+	minorUnits  = 100
+)
+
+// Money is an exact amount of money stored in minor units (kopecks,
+// cents) of its currency. It's rendered in JSON as a decimal string,
+// e.g. {"amount": "12.34", "currency": "RUB"}.
+type Money struct {
+	Amount   int64  `json:"amount" swaggertype:"string" example:"12.34"`
+	Currency string `json:"currency" example:"RUB"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	return Money{
+		Amount:   amount,
+		Currency: strings.ToUpper(currency),
+	}
+}
+
+// ParseCurrency checks that s looks like an ISO 4217 code, three latin
+// letters, and returns it in upper case, so "rub" is "RUB". Empty s is
+// the default currency.
+func ParseCurrency(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DefaultCurrency, nil
+	}
+
+	if len(s) != 3 {
+		return "", ErrInvalidCurrency
+	}
+
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return "", ErrInvalidCurrency
+		}
+	}
+
+	return strings.ToUpper(s), nil
+}
+
+// ParseMoney parses a decimal string like "12.34" into money of the
+// given currency. Amounts that don't fit in int64 minor units are
+// ErrMoneyOverflow.
+func ParseMoney(s, currency string) (Money, error) {
+	currency, err := ParseCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s = strings.TrimSpace(s)
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	major, minor, found := strings.Cut(s, ".")
+	if major == "" || (found && (minor == "" || len(minor) > minorDigits)) {
+		return Money{}, ErrInvalidMoney
+	}
+	minor += strings.Repeat("0", minorDigits-len(minor))
+
+	majorVal, err := strconv.ParseUint(major, 10, 63)
+	if errors.Is(err, strconv.ErrRange) {
+		return Money{}, ErrMoneyOverflow
+	}
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+
+	minorVal, err := strconv.ParseUint(minor, 10, 63)
+	if err != nil {
+		return Money{}, ErrInvalidMoney
+	}
+
+	if majorVal > (math.MaxInt64-minorVal)/minorUnits {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	amount := int64(majorVal*minorUnits + minorVal)
+	if neg {
+		amount = -amount
+	}
+
+	return NewMoney(amount, currency), nil
+}
+
+func (m Money) String() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, amount/minorUnits, amount%minorUnits)
+}
+
+// Float returns the amount in major units. It isn't exact and is meant
+// for metrics only.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / minorUnits
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Neg() Money {
+	return NewMoney(-m.Amount, m.Currency)
+}
+
+// Mul returns m times n, or ErrMoneyOverflow if the product doesn't fit
+// in int64 minor units.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.Amount == 0 || n == 0 {
+		return NewMoney(0, m.Currency), nil
+	}
+
+	product := m.Amount * n
+	if product/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return NewMoney(product, m.Currency), nil
+}
+
+// Add returns the sum of m and o. Zero money of any currency can be added
+// to anything, otherwise currencies must match. Sums that don't fit in
+// int64 minor units are ErrMoneyOverflow.
+func (m Money) Add(o Money) (Money, error) {
+	switch {
+	case o.IsZero() && o.Currency != m.Currency:
+		return m, nil
+	case m.IsZero() && o.Currency != m.Currency:
+		return o, nil
+	case o.Currency != m.Currency:
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return NewMoney(m.Amount+o.Amount, m.Currency), nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return m.Add(o.Neg())
+}
+
+// Less reports whether m is smaller than o. Currencies must match.
+func (m Money) Less(o Money) (bool, error) {
+	if m.Currency != o.Currency && !m.IsZero() && !o.IsZero() {
+		return false, ErrCurrencyMismatch
+	}
+
+	return m.Amount < o.Amount, nil
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{
+		Amount:   m.String(),
+		Currency: currency,
+	})
+}
+
+// UnmarshalJSON accepts {"amount": "12.34", "currency": "RUB"} as well as
+// a bare "12.34" or 12.34 in the default currency. Amount may be either
+// a string or a number, it's parsed as decimal text without going
+// through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = []byte(strings.TrimSpace(string(data)))
+	if len(data) > 0 && data[0] == '{' {
+		var raw moneyJSON
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+
+		return m.parseAmount(raw.Amount, raw.Currency)
+	}
+
+	return m.parseAmount(data, "")
+}
+
+func (m *Money) parseAmount(raw json.RawMessage, currency string) error {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		var num json.Number
+		if err := json.Unmarshal(raw, &num); err != nil {
+			return ErrInvalidMoney
+		}
+
+		str = num.String()
+	}
+
+	money, err := ParseMoney(str, currency)
+	if err != nil {
+		return err
+	}
+
+	*m = money
+
+	return nil
+}
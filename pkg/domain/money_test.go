@@ -0,0 +1,189 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		s, currency string
+		want        domain.Money
+		err         error
+	}{
+		{"12.34", "RUB", domain.Money{Amount: 1234, Currency: "RUB"}, nil},
+		{"12.3", "RUB", domain.Money{Amount: 1230, Currency: "RUB"}, nil},
+		{"12", "RUB", domain.Money{Amount: 1200, Currency: "RUB"}, nil},
+		{"0.05", "RUB", domain.Money{Amount: 5, Currency: "RUB"}, nil},
+		{"-1.50", "RUB", domain.Money{Amount: -150, Currency: "RUB"}, nil},
+		{" 7.00 ", "RUB", domain.Money{Amount: 700, Currency: "RUB"}, nil},
+		{"1.00", "", domain.Money{Amount: 100, Currency: domain.DefaultCurrency}, nil},
+		{"1.00", "usd", domain.Money{Amount: 100, Currency: "USD"}, nil},
+		{"92233720368547758.07", "RUB", domain.Money{Amount: math.MaxInt64, Currency: "RUB"}, nil},
+
+		{"92233720368547758.08", "RUB", domain.Money{}, domain.ErrMoneyOverflow},
+		{"-92233720368547758.08", "RUB", domain.Money{}, domain.ErrMoneyOverflow},
+		{"92233720368547759", "RUB", domain.Money{}, domain.ErrMoneyOverflow},
+		{"99999999999999999999999", "RUB", domain.Money{}, domain.ErrMoneyOverflow},
+		{"", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"-", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{".50", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"1.", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"1.234", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"1e3", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"+1", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"1.-5", "RUB", domain.Money{}, domain.ErrInvalidMoney},
+		{"1.00", "RU", domain.Money{}, domain.ErrInvalidCurrency},
+		{"1.00", "RUBL", domain.Money{}, domain.ErrInvalidCurrency},
+		{"1.00", "R1B", domain.Money{}, domain.ErrInvalidCurrency},
+		{"1.00", "РУБ", domain.Money{}, domain.ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.ParseMoney(tt.s, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q, %q) error = %v, want %v", tt.s, tt.currency, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %+v, want %+v", tt.s, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1234, "12.34"},
+		{-150, "-1.50"},
+	}
+
+	for _, tt := range tests {
+		if got := domain.NewMoney(tt.amount, "RUB").String(); got != tt.want {
+			t.Errorf("NewMoney(%d).String() = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	for _, m := range []domain.Money{
+		domain.NewMoney(0, "RUB"),
+		domain.NewMoney(1234, "RUB"),
+		domain.NewMoney(-150, "USD"),
+		domain.NewMoney(math.MaxInt64, "EUR"),
+	} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%v) error = %v", m, err)
+		}
+
+		var got domain.Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("Unmarshal(%s) error = %v", data, err)
+		}
+
+		if got != m {
+			t.Errorf("Unmarshal(Marshal(%+v)) = %+v via %s", m, got, data)
+		}
+	}
+
+	tests := []struct {
+		data string
+		want domain.Money
+		err  error
+	}{
+		{`{"amount": "12.34", "currency": "RUB"}`, domain.Money{Amount: 1234, Currency: "RUB"}, nil},
+		{`{"amount": 12.34, "currency": "usd"}`, domain.Money{Amount: 1234, Currency: "USD"}, nil},
+		{`{"amount": "1"}`, domain.Money{Amount: 100, Currency: domain.DefaultCurrency}, nil},
+		{`"0.10"`, domain.Money{Amount: 10, Currency: domain.DefaultCurrency}, nil},
+		{`0.10`, domain.Money{Amount: 10, Currency: domain.DefaultCurrency}, nil},
+		{`{"amount": 1e2}`, domain.Money{}, domain.ErrInvalidMoney},
+		{`{"amount": "0.001"}`, domain.Money{}, domain.ErrInvalidMoney},
+		{`{"amount": "100000000000000000"}`, domain.Money{}, domain.ErrMoneyOverflow},
+		{`{"amount": true}`, domain.Money{}, domain.ErrInvalidMoney},
+		{`{"amount": "1.00", "currency": "rubles"}`, domain.Money{}, domain.ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		var got domain.Money
+		err := json.Unmarshal([]byte(tt.data), &got)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Unmarshal(%s) error = %v, want %v", tt.data, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAdd(t *testing.T) {
+	rub := func(amount int64) domain.Money { return domain.NewMoney(amount, "RUB") }
+
+	tests := []struct {
+		a, b domain.Money
+		want domain.Money
+		err  error
+	}{
+		{rub(100), rub(250), rub(350), nil},
+		{rub(100), rub(-250), rub(-150), nil},
+		{rub(100), domain.NewMoney(0, "USD"), rub(100), nil},
+		{domain.NewMoney(0, "USD"), rub(100), rub(100), nil},
+		{rub(math.MaxInt64 - 1), rub(1), rub(math.MaxInt64), nil},
+		{rub(math.MaxInt64), rub(1), domain.Money{}, domain.ErrMoneyOverflow},
+		{rub(math.MinInt64), rub(-1), domain.Money{}, domain.ErrMoneyOverflow},
+		{rub(100), domain.NewMoney(100, "USD"), domain.Money{}, domain.ErrCurrencyMismatch},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("%+v.Add(%+v) = %+v, %v, want %+v, %v", tt.a, tt.b, got, err, tt.want, tt.err)
+		}
+	}
+
+	if _, err := rub(0).Sub(rub(math.MinInt64)); !errors.Is(err, domain.ErrMoneyOverflow) {
+		t.Errorf("Sub(MinInt64) error = %v, want %v", err, domain.ErrMoneyOverflow)
+	}
+}
+
+func TestMoneyMul(t *testing.T) {
+	tests := []struct {
+		amount, n int64
+		want      int64
+		err       error
+	}{
+		{250, 3, 750, nil},
+		{250, 0, 0, nil},
+		{0, math.MaxInt64, 0, nil},
+		{-250, 2, -500, nil},
+		{math.MaxInt64, 1, math.MaxInt64, nil},
+		{math.MaxInt64 / 2, 2, math.MaxInt64 - 1, nil},
+		{math.MaxInt64/2 + 1, 2, 0, domain.ErrMoneyOverflow},
+		{1 << 32, 1 << 32, 0, domain.ErrMoneyOverflow},
+		{math.MinInt64, -1, 0, domain.ErrMoneyOverflow},
+		{-1, math.MinInt64, 0, domain.ErrMoneyOverflow},
+	}
+
+	for _, tt := range tests {
+		got, err := domain.NewMoney(tt.amount, "RUB").Mul(tt.n)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Mul(%d, %d) error = %v, want %v", tt.amount, tt.n, err, tt.err)
+			continue
+		}
+
+		if err == nil && got.Amount != tt.want {
+			t.Errorf("Mul(%d, %d) = %d, want %d", tt.amount, tt.n, got.Amount, tt.want)
+		}
+	}
+}
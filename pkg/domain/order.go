@@ -9,26 +9,39 @@ type StatusID string
 var (
 	CREATED   StatusID = "created"
 	PAID      StatusID = "paid"
+	SHIPPED   StatusID = "shipped"
 	DELIVERED StatusID = "delivered"
+	CANCELLED StatusID = "cancelled"
+	REFUNDED  StatusID = "refunded"
+	EXPIRED   StatusID = "expired"
 )
 
+// OrderItem is a line of an order. Price and SellerID are filled in when
+// the order is placed and are ignored in requests.
 type OrderItem struct {
-	ID       string `json:"item_id"`
-	Quantity int64  `json:"quantity"`
+	ID       string `json:"item_id" binding:"required,id"`
+	Quantity int64  `json:"quantity" binding:"gt=0"`
+	Price    Money  `json:"price"`
+	SellerID string `json:"seller_id,omitempty"`
 }
 
 type Order struct {
-	ID         string      `json:"id"`
-	Total      float64     `json:"total"`
-	Items      []OrderItem `json:"items"`
-	CreatedAt  time.Time   `json:"created_at"`
-	Status     StatusID    `json:"status"`
-	CustomerID string      `json:"customer_id"`
+	ID          string             `json:"id"`
+	Total       Money              `json:"total"`
+	Items       []OrderItem        `json:"items"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Status      StatusID           `json:"status"`
+	CustomerID  string             `json:"customer_id"`
+	Transitions []StatusTransition `json:"transitions"`
 }
 
 type CreateOrderRequest struct {
-	Items      []OrderItem `json:"items"`
-	CustomerID string      `json:"customer_id"`
+	Items      []OrderItem `json:"items" binding:"required,min=1,dive"`
+	CustomerID string      `json:"customer_id" binding:"required,id"`
+	// CartVersion is set when the order is checked out from the cart of
+	// the customer. Storages clear the cart along with creating the
+	// order, or fail with ErrCartChanged if its version is different.
+	CartVersion int64 `json:"-" swaggerignore:"true"`
 }
 
 type UpdateOrderRequest struct {
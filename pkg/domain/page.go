@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// SortOrder names an order of list results. Items can be sorted by
+// creation time or price, users, orders and transactions only by
+// creation time.
+type SortOrder string
+
+const (
+	SortNewest    SortOrder = "newest"
+	SortOldest    SortOrder = "oldest"
+	SortPriceAsc  SortOrder = "price_asc"
+	SortPriceDesc SortOrder = "price_desc"
+)
+
+var (
+	ItemSorts = []SortOrder{SortNewest, SortOldest, SortPriceAsc, SortPriceDesc}
+	UserSorts = []SortOrder{SortNewest, SortOldest}
+
+	OrderSorts       = []SortOrder{SortNewest, SortOldest}
+	TransactionSorts = []SortOrder{SortNewest, SortOldest}
+)
+
+// ByPrice reports whether records are ordered by price rather than by
+// creation time.
+func (s SortOrder) ByPrice() bool {
+	return s == SortPriceAsc || s == SortPriceDesc
+}
+
+func (s SortOrder) Descending() bool {
+	return s == SortNewest || s == SortPriceDesc
+}
+
+// ParseSortOrder returns the sort order named s if it's one of allowed.
+func ParseSortOrder(s string, allowed []SortOrder) (SortOrder, error) {
+	for _, sort := range allowed {
+		if string(sort) == s {
+			return sort, nil
+		}
+	}
+
+	return "", WithDetails(ErrInvalidSort, map[string][]SortOrder{"allowed": allowed})
+}
+
+// Cursor points to the last record of a page: its sort key and ID. The
+// next page starts right after it, IDs break ties between equal keys.
+// Clients get cursors encoded and pass them back as they are.
+type Cursor struct {
+	Sort SortOrder `json:"s"`
+	Key  int64     `json:"k"`
+	ID   string    `json:"id"`
+}
+
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor returned with a page sorted in sort
+// order. Cursors of pages sorted differently are rejected.
+func DecodeCursor(s string, sort SortOrder) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// PageRequest asks for at most Limit records in Sort order, starting
+// after the After cursor or from the beginning if it's nil.
+type PageRequest struct {
+	Limit int64
+	Sort  SortOrder
+	After *Cursor
+}
+
+// ItemFilter selects items to list. Zero fields don't restrict the
+// result, set ones all have to match.
+type ItemFilter struct {
+	// Categories matches items in any of the categories.
+	Categories []string
+	// Currency keeps items priced in the currency only.
+	Currency string
+	// MinPrice and MaxPrice bound the price inclusively. Only items in
+	// the currency of the bounds match, both bounds must use the same.
+	MinPrice     *Money
+	MaxPrice     *Money
+	InStock      bool
+	OwnerID      string
+	CreatedAfter time.Time
+}
+
+func (f *ItemFilter) Validate() error {
+	currency := f.PriceCurrency()
+	for _, bound := range []*Money{f.MinPrice, f.MaxPrice} {
+		if bound != nil && bound.Currency != currency {
+			return ErrCurrencyMismatch
+		}
+	}
+
+	return nil
+}
+
+// PriceCurrency returns the currency items are restricted to, or an
+// empty string if any currency matches.
+func (f *ItemFilter) PriceCurrency() string {
+	switch {
+	case f.Currency != "":
+		return f.Currency
+	case f.MinPrice != nil:
+		return f.MinPrice.Currency
+	case f.MaxPrice != nil:
+		return f.MaxPrice.Currency
+	}
+
+	return ""
+}
+
+type ItemsPage struct {
+	Items      []*Item     `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Facets     *ItemFacets `json:"facets,omitempty"`
+}
+
+type UsersPage struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type OrdersPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type TransactionsPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+}
+
+// SortKey returns the value items are ordered by in sort order.
+func (it *Item) SortKey(sort SortOrder) int64 {
+	if sort.ByPrice() {
+		return it.Price.Amount
+	}
+
+	return it.CreatedAt.UnixNano()
+}
+
+func (u *User) SortKey(SortOrder) int64 {
+	return u.CreatedAt.UnixNano()
+}
+
+func (o *Order) SortKey(SortOrder) int64 {
+	return o.CreatedAt.UnixNano()
+}
+
+func (t *Transaction) SortKey(SortOrder) int64 {
+	return t.CreatedAt.UnixNano()
+}
+
+// NewItemsPage builds a page from items fetched in page order. Storages
+// fetch one item more than the limit: if it's there, the page isn't the
+// last one and gets a cursor.
+func NewItemsPage(items []*Item, page PageRequest) *ItemsPage {
+	result := &ItemsPage{Items: items}
+	if page.Limit > 0 && int64(len(items)) > page.Limit {
+		result.Items = items[:page.Limit]
+
+		last := result.Items[page.Limit-1]
+		result.NextCursor = (&Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.ID}).Encode()
+	}
+
+	return result
+}
+
+// NewUsersPage is NewItemsPage for users.
+func NewUsersPage(users []*User, page PageRequest) *UsersPage {
+	result := &UsersPage{Users: users}
+	if page.Limit > 0 && int64(len(users)) > page.Limit {
+		result.Users = users[:page.Limit]
+
+		last := result.Users[page.Limit-1]
+		result.NextCursor = (&Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.ID}).Encode()
+	}
+
+	return result
+}
+
+// NewOrdersPage is NewItemsPage for orders.
+func NewOrdersPage(orders []*Order, page PageRequest) *OrdersPage {
+	result := &OrdersPage{Orders: orders}
+	if page.Limit > 0 && int64(len(orders)) > page.Limit {
+		result.Orders = orders[:page.Limit]
+
+		last := result.Orders[page.Limit-1]
+		result.NextCursor = (&Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.ID}).Encode()
+	}
+
+	return result
+}
+
+// NewTransactionsPage is NewItemsPage for transactions.
+func NewTransactionsPage(txs []*Transaction, page PageRequest) *TransactionsPage {
+	result := &TransactionsPage{Transactions: txs}
+	if page.Limit > 0 && int64(len(txs)) > page.Limit {
+		result.Transactions = txs[:page.Limit]
+
+		last := result.Transactions[page.Limit-1]
+		result.NextCursor = (&Cursor{Sort: page.Sort, Key: last.SortKey(page.Sort), ID: last.ID}).Encode()
+	}
+
+	return result
+}
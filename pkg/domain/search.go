@@ -0,0 +1,245 @@
+package domain
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxSearchQueryLength limits search queries in bytes.
+const MaxSearchQueryLength = 256
+
+// Weights of item fields in search scores: a match in the name counts
+// more than one in the description. The Mongo text index is created
+// with the same weights.
+const (
+	SearchWeightName     = 10
+	SearchWeightCategory = 5
+	SearchWeightDesc     = 1
+)
+
+const (
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+
+	// Long descriptions are highlighted around the first match only.
+	fragmentWords = 24
+)
+
+type SearchRequest struct {
+	Query string
+	Limit int64
+}
+
+func (r *SearchRequest) Validate() error {
+	if len(r.Query) > MaxSearchQueryLength || len(SearchTerms(r.Query)) == 0 {
+		return ErrInvalidSearchQuery
+	}
+
+	return nil
+}
+
+// SearchHit is an item matching a search query. Highlights hold matched
+// fields by their JSON names as HTML: the text is escaped and matched
+// words are wrapped in <em> tags.
+type SearchHit struct {
+	Item       *Item             `json:"item"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type SearchResult struct {
+	Hits []*SearchHit `json:"hits"`
+}
+
+// SearchTerms splits a query into distinct lowercase words. Anything
+// but letters and digits separates words.
+func SearchTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := make([]string, 0)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), isSeparator) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+
+	return terms
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// ItemMatcher searches items in process for storages without full-text
+// search. A word matches a term if it starts with it, so "phone" finds
+// "phones" much like the stemming of the Mongo text index does.
+type ItemMatcher struct {
+	terms []string
+}
+
+func NewItemMatcher(query string) *ItemMatcher {
+	return &ItemMatcher{terms: SearchTerms(query)}
+}
+
+// Match scores the item and highlights its matched fields. Every field
+// adds its weight times the share of its words that match.
+func (m *ItemMatcher) Match(it *Item) (*SearchHit, bool) {
+	hit := &SearchHit{Item: it}
+
+	fields := []struct {
+		name   string
+		text   string
+		weight float64
+	}{
+		{"name", it.Name, SearchWeightName},
+		{"category", it.Category, SearchWeightCategory},
+		{"desc", it.Description, SearchWeightDesc},
+	}
+
+	for _, f := range fields {
+		text, matched, total := m.highlight(f.text)
+		if matched == 0 {
+			continue
+		}
+
+		if hit.Highlights == nil {
+			hit.Highlights = make(map[string]string)
+		}
+		hit.Highlights[f.name] = text
+		hit.Score += f.weight * float64(matched) / float64(total)
+	}
+
+	return hit, hit.Score > 0
+}
+
+// Highlight returns highlights of the item fields without scoring it.
+// It's used for items found by a storage with its own scoring.
+func (m *ItemMatcher) Highlight(it *Item) map[string]string {
+	hit, ok := m.Match(it)
+	if !ok {
+		return nil
+	}
+
+	return hit.Highlights
+}
+
+func (m *ItemMatcher) matches(word string) bool {
+	word = strings.ToLower(word)
+	for _, term := range m.terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highlight escapes text as HTML, wraps its matched words in tags and
+// counts them. Sellers write the text, so it's never trusted as markup.
+func (m *ItemMatcher) highlight(text string) (string, int, int) {
+	segments := splitWords(text)
+
+	var (
+		matched, total int
+		first          = -1
+		words          []int
+	)
+	for i, s := range segments {
+		segments[i].text = html.EscapeString(s.text)
+		if !s.word {
+			continue
+		}
+
+		total++
+		words = append(words, i)
+		if m.matches(s.text) {
+			matched++
+			segments[i].text = highlightStart + segments[i].text + highlightEnd
+			if first < 0 {
+				first = len(words) - 1
+			}
+		}
+	}
+
+	if matched == 0 {
+		return "", 0, total
+	}
+
+	from, to := 0, len(segments)
+	if total > fragmentWords {
+		start := first - fragmentWords/4
+		if start < 0 {
+			start = 0
+		}
+		end := start + fragmentWords
+		if end > total {
+			end, start = total, total-fragmentWords
+		}
+
+		from = words[start]
+		to = words[end-1] + 1
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	for _, s := range segments[from:to] {
+		b.WriteString(s.text)
+	}
+	if to < len(segments) {
+		b.WriteString("…")
+	}
+
+	return b.String(), matched, total
+}
+
+type segment struct {
+	text string
+	word bool
+}
+
+// splitWords splits text into alternating runs of word and separator
+// characters, so joining them gives the text back.
+func splitWords(text string) []segment {
+	var segments []segment
+	for len(text) > 0 {
+		r, _ := utf8.DecodeRuneInString(text)
+		word := !isSeparator(r)
+
+		end := strings.IndexFunc(text, func(r rune) bool { return isSeparator(r) == word })
+		if end < 0 {
+			end = len(text)
+		}
+
+		segments = append(segments, segment{text: text[:end], word: word})
+		text = text[end:]
+	}
+
+	return segments
+}
+
+// RankHits sorts hits by score, newer items first among equal scores,
+// and returns at most limit of them.
+func RankHits(hits []*SearchHit, limit int64) []*SearchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Item.CreatedAt.Equal(b.Item.CreatedAt) {
+			return a.Item.CreatedAt.After(b.Item.CreatedAt)
+		}
+
+		return a.Item.ID < b.Item.ID
+	})
+
+	if int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+
+	return hits
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	ActorCustomer = "customer"
+	ActorStaff    = "staff"
+	ActorSystem   = "system"
+)
+
+type StatusTransition struct {
+	From  StatusID  `json:"from"`
+	To    StatusID  `json:"to"`
+	At    time.Time `json:"at"`
+	Actor string    `json:"actor"`
+}
+
+// orderTransitions lists statuses reachable from each status.
+// Cancelled, refunded and expired orders are final.
+var orderTransitions = map[StatusID][]StatusID{
+	CREATED:   {PAID, CANCELLED, EXPIRED},
+	PAID:      {SHIPPED, DELIVERED, REFUNDED},
+	SHIPPED:   {DELIVERED, REFUNDED},
+	DELIVERED: {REFUNDED},
+}
+
+func (s StatusID) CanTransitionTo(to StatusID) bool {
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransitionTo checks that the order may move from s to the status to
+// and returns the error describing why it can't otherwise.
+func (s StatusID) TransitionTo(to StatusID) error {
+	if s.CanTransitionTo(to) {
+		return nil
+	}
+
+	switch s {
+	case to:
+		return ErrOrderStatusUnchanged
+	case DELIVERED:
+		return ErrOrderAlreadyDelivered
+	case CANCELLED:
+		return ErrOrderCancelled
+	case REFUNDED:
+		return ErrOrderRefunded
+	case EXPIRED:
+		return ErrOrderExpired
+	}
+
+	if s == CREATED && (to == SHIPPED || to == DELIVERED || to == REFUNDED) {
+		return ErrOrderNotPaid
+	}
+
+	return ErrOrderInvalidTransition
+}
+
+// ReleasesStock reports whether the transition returns the stock
+// reserved for the order. Stock is reserved when an order is created and
+// goes back if the order is cancelled or expires before it's paid.
+func (tr StatusTransition) ReleasesStock() bool {
+	return tr.From == CREATED && (tr.To == CANCELLED || tr.To == EXPIRED)
+}
+
+func NewStatusTransition(from, to StatusID, actor string) StatusTransition {
+	return StatusTransition{
+		From:  from,
+		To:    to,
+		At:    time.Now(),
+		Actor: actor,
+	}
+}
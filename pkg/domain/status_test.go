@@ -0,0 +1,75 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+)
+
+func TestStatusTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to domain.StatusID
+		want     error
+	}{
+		{domain.CREATED, domain.PAID, nil},
+		{domain.CREATED, domain.CANCELLED, nil},
+		{domain.CREATED, domain.EXPIRED, nil},
+		{domain.PAID, domain.SHIPPED, nil},
+		{domain.PAID, domain.DELIVERED, nil},
+		{domain.PAID, domain.REFUNDED, nil},
+		{domain.SHIPPED, domain.DELIVERED, nil},
+		{domain.SHIPPED, domain.REFUNDED, nil},
+		{domain.DELIVERED, domain.REFUNDED, nil},
+
+		{domain.CREATED, domain.CREATED, domain.ErrOrderStatusUnchanged},
+		{domain.PAID, domain.PAID, domain.ErrOrderStatusUnchanged},
+		{domain.CANCELLED, domain.CANCELLED, domain.ErrOrderStatusUnchanged},
+		{domain.CREATED, domain.SHIPPED, domain.ErrOrderNotPaid},
+		{domain.CREATED, domain.DELIVERED, domain.ErrOrderNotPaid},
+		{domain.CREATED, domain.REFUNDED, domain.ErrOrderNotPaid},
+		{domain.DELIVERED, domain.PAID, domain.ErrOrderAlreadyDelivered},
+		{domain.DELIVERED, domain.SHIPPED, domain.ErrOrderAlreadyDelivered},
+		{domain.CANCELLED, domain.PAID, domain.ErrOrderCancelled},
+		{domain.REFUNDED, domain.PAID, domain.ErrOrderRefunded},
+		{domain.REFUNDED, domain.SHIPPED, domain.ErrOrderRefunded},
+		{domain.EXPIRED, domain.PAID, domain.ErrOrderExpired},
+		{domain.EXPIRED, domain.CANCELLED, domain.ErrOrderExpired},
+		{domain.PAID, domain.CREATED, domain.ErrOrderInvalidTransition},
+		{domain.PAID, domain.CANCELLED, domain.ErrOrderInvalidTransition},
+		{domain.PAID, domain.EXPIRED, domain.ErrOrderInvalidTransition},
+		{domain.SHIPPED, domain.PAID, domain.ErrOrderInvalidTransition},
+		{domain.SHIPPED, domain.CANCELLED, domain.ErrOrderInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		err := tt.from.TransitionTo(tt.to)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s.TransitionTo(%s) = %v, want %v", tt.from, tt.to, err, tt.want)
+		}
+
+		if got, want := tt.from.CanTransitionTo(tt.to), tt.want == nil; got != want {
+			t.Errorf("%s.CanTransitionTo(%s) = %t, want %t", tt.from, tt.to, got, want)
+		}
+	}
+}
+
+func TestStatusTransitionReleasesStock(t *testing.T) {
+	tests := []struct {
+		from, to domain.StatusID
+		want     bool
+	}{
+		{domain.CREATED, domain.CANCELLED, true},
+		{domain.CREATED, domain.EXPIRED, true},
+		{domain.CREATED, domain.PAID, false},
+		{domain.PAID, domain.REFUNDED, false},
+		{domain.SHIPPED, domain.DELIVERED, false},
+	}
+
+	for _, tt := range tests {
+		tr := domain.NewStatusTransition(tt.from, tt.to, domain.ActorSystem)
+		if got := tr.ReleasesStock(); got != tt.want {
+			t.Errorf("transition %s -> %s ReleasesStock() = %t, want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+)
+
+type TransactionKind string
+
+var (
+	TOPUP      TransactionKind = "topup"
+	WITHDRAWAL TransactionKind = "withdrawal"
+	PAYMENT    TransactionKind = "payment"
+	PAYOUT     TransactionKind = "payout"
+	REFUND     TransactionKind = "refund"
+)
+
+// Transaction is an entry of the balance ledger. Amount is positive when
+// the user's balance grows and negative when it shrinks.
+type Transaction struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	OrderID   string          `json:"order_id,omitempty"`
+	Kind      TransactionKind `json:"kind"`
+	Amount    Money           `json:"amount"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type PostTransactionRequest struct {
+	UserID  string          `json:"user_id"`
+	OrderID string          `json:"order_id,omitempty"`
+	Kind    TransactionKind `json:"kind"`
+	Amount  Money           `json:"amount"`
+}
+
+type TopUpRequest struct {
+	Amount Money `json:"amount" binding:"gt=0"`
+}
+
+type WithdrawRequest struct {
+	Amount Money `json:"amount" binding:"gt=0"`
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"sort"
 	"time"
 )
 
@@ -10,11 +11,59 @@ type User struct {
 	Email     string    `json:"email"`
 	Phone     string    `json:"phone"`
 	CreatedAt time.Time `json:"created_at"`
-	Balance   uint64    `json:"balance"`
+	Balance   Money     `json:"balance"`
+	Roles     []Role    `json:"roles"`
 }
 
 type RegisterUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Phone string `json:"phone"`
+	Name  string `json:"name" binding:"required,max=100"`
+	Email string `json:"email" binding:"required,email,max=254"`
+	Phone string `json:"phone" binding:"omitempty,max=32"`
+	// Password is hashed by the shop, storages only get PasswordHash.
+	Password     string `json:"password" binding:"required,min=8,maxbytes=72"`
+	PasswordHash []byte `json:"-" swaggerignore:"true"`
+}
+
+// Role grants a user rights beyond buying, which every user has.
+// Sellers add items, staff handle orders, admins can do anything and
+// manage roles of other users.
+type Role string
+
+const (
+	RoleSeller Role = "seller"
+	RoleStaff  Role = "staff"
+	RoleAdmin  Role = "admin"
+)
+
+var Roles = []Role{RoleSeller, RoleStaff, RoleAdmin}
+
+// ParseRole returns the role named s.
+func ParseRole(s string) (Role, error) {
+	for _, role := range Roles {
+		if string(role) == s {
+			return role, nil
+		}
+	}
+
+	return "", WithDetails(ErrInvalidRole, map[string][]Role{"allowed": Roles})
+}
+
+// HasRole reports whether the user has the role. Admins have them all.
+func (u *User) HasRole(role Role) bool {
+	for _, r := range u.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SortRoles sorts roles by name, so storages list them the same way.
+func SortRoles(roles []Role) {
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+}
+
+type RoleRequest struct {
+	Role Role `json:"role" binding:"required,oneof=seller staff admin" example:"seller"`
 }
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"encoding/hex"
+)
+
+// FieldError describes a request field that failed validation. Reason
+// is the name of the violated rule, e.g. "required" or "email", and
+// Param is the rule argument if it has one.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+	Param  string `json:"param,omitempty"`
+}
+
+func NewValidationError(fields []FieldError) error {
+	return WithDetails(ErrValidation, map[string][]FieldError{"fields": fields})
+}
+
+// IsID reports whether id looks like an identifier of any supported
+// storage: 24 hex characters (MongoDB, SQLite, memory) or a UUID
+// (PostgreSQL). Storages still check the exact format themselves.
+func IsID(id string) bool {
+	switch len(id) {
+	case 24:
+		_, err := hex.DecodeString(id)
+		return err == nil
+	case 36:
+		for i, c := range id {
+			switch {
+			case i == 8 || i == 13 || i == 18 || i == 23:
+				if c != '-' {
+					return false
+				}
+			case (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F'):
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
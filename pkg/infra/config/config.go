@@ -7,24 +7,105 @@ import (
 )
 
 type MongoCfg struct {
-	Uri     string        `mapstructure:"mongo_uri"`
-	Timeout time.Duration `mapstructure:"mongo_timeout"`
+	Uri            string        `mapstructure:"mongo_uri"`
+	Database       string        `mapstructure:"mongo_database"`
+	Timeout        time.Duration `mapstructure:"mongo_timeout"`
+	MigrateOnStart bool          `mapstructure:"mongo_migrate_on_start"`
+}
+
+type PostgresCfg struct {
+	DSN     string        `mapstructure:"postgres_dsn"`
+	Timeout time.Duration `mapstructure:"postgres_timeout"`
+}
+
+type SQLiteCfg struct {
+	Path    string        `mapstructure:"sqlite_path"`
+	Timeout time.Duration `mapstructure:"sqlite_timeout"`
+}
+
+type HTTPCfg struct {
+	Addr            string        `mapstructure:"http_addr"`
+	ReadTimeout     time.Duration `mapstructure:"http_read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"http_write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"http_idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"http_shutdown_timeout"`
+	DrainDelay      time.Duration `mapstructure:"http_drain_delay"`
+	TLSCert         string        `mapstructure:"http_tls_cert"`
+	TLSKey          string        `mapstructure:"http_tls_key"`
+}
+
+// AuthCfg configures user tokens. Tokens are signed with Secret, if it's
+// empty a random one is used and tokens don't survive restarts.
+type AuthCfg struct {
+	Secret     string        `mapstructure:"auth_secret"`
+	AccessTTL  time.Duration `mapstructure:"auth_access_ttl"`
+	RefreshTTL time.Duration `mapstructure:"auth_refresh_ttl"`
+	BcryptCost int           `mapstructure:"auth_bcrypt_cost"`
+}
+
+// IdempotencyCfg configures idempotency keys. Responses to requests with
+// keys are replayed for TTL. LockTTL is how long a key stays taken by a
+// request that never finished, e.g. because the server crashed.
+type IdempotencyCfg struct {
+	TTL     time.Duration `mapstructure:"idempotency_ttl"`
+	LockTTL time.Duration `mapstructure:"idempotency_lock_ttl"`
 }
 
 type Config struct {
-	Mongo             MongoCfg      `mapstructure:",squash"`
-	RecentItemsPeriod time.Duration `mapstructure:"recent_items_period"`
-	RecentUsersCount  int64         `mapstructure:"recent_users_count"`
+	HTTP              HTTPCfg        `mapstructure:",squash"`
+	DBDriver          string         `mapstructure:"db_driver"`
+	Mongo             MongoCfg       `mapstructure:",squash"`
+	Postgres          PostgresCfg    `mapstructure:",squash"`
+	SQLite            SQLiteCfg      `mapstructure:",squash"`
+	Auth              AuthCfg        `mapstructure:",squash"`
+	Idempotency       IdempotencyCfg `mapstructure:",squash"`
+	RecentItemsPeriod time.Duration  `mapstructure:"recent_items_period"`
+	RecentUsersCount  int64          `mapstructure:"recent_users_count"`
+	OrderPaymentTTL   time.Duration  `mapstructure:"order_payment_ttl"`
+	OrderExpiryPeriod time.Duration  `mapstructure:"order_expiry_period"`
+	PageLimit         int64          `mapstructure:"page_limit"`
+	MaxPageLimit      int64          `mapstructure:"max_page_limit"`
 }
 
 func Get() (*Config, error) {
 	config := new(Config)
 
+	viper.SetDefault("http_addr", ":8080")
+	viper.SetDefault("http_read_timeout", "15s")
+	viper.SetDefault("http_write_timeout", "15s")
+	viper.SetDefault("http_idle_timeout", "60s")
+	viper.SetDefault("http_shutdown_timeout", "30s")
+	viper.SetDefault("http_drain_delay", "0s")
+	viper.SetDefault("http_tls_cert", "")
+	viper.SetDefault("http_tls_key", "")
+
+	viper.SetDefault("db_driver", "mongo")
+
 	viper.SetDefault("mongo_uri", "mongodb://localhost:27017")
+	viper.SetDefault("mongo_database", "shop")
 	viper.SetDefault("mongo_timeout", "10s")
+	viper.SetDefault("mongo_migrate_on_start", true)
+
+	viper.SetDefault("postgres_dsn", "postgres://localhost:5432/shop?sslmode=disable")
+	viper.SetDefault("postgres_timeout", "10s")
+
+	viper.SetDefault("sqlite_path", "shop.db")
+	viper.SetDefault("sqlite_timeout", "10s")
+
+	viper.SetDefault("auth_secret", "")
+	viper.SetDefault("auth_access_ttl", "15m")
+	viper.SetDefault("auth_refresh_ttl", "720h")
+	viper.SetDefault("auth_bcrypt_cost", 10)
+
+	viper.SetDefault("idempotency_ttl", "24h")
+	viper.SetDefault("idempotency_lock_ttl", "1m")
 
 	viper.SetDefault("recent_items_period", "72h")
 	viper.SetDefault("recent_users_count", 2)
+	viper.SetDefault("order_payment_ttl", "24h")
+	viper.SetDefault("order_expiry_period", "1m")
+	viper.SetDefault("page_limit", 20)
+	viper.SetDefault("max_page_limit", 100)
 
 	viper.AutomaticEnv()
 
@@ -0,0 +1,65 @@
+// Package metrics holds Prometheus collectors of the shop. They are
+// registered in the default registry, which is served at /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "shop"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of storage operations per db.DB method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"driver", "method", "outcome"})
+
+	Orders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_total",
+		Help:      "Number of orders that reached a status.",
+	}, []string{"status"})
+
+	Revenue = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "revenue_total",
+		Help:      "Paid order totals in major currency units.",
+	}, []string{"currency"})
+
+	Refunds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refunds_total",
+		Help:      "Refunded order totals in major currency units.",
+	}, []string{"currency"})
+
+	ItemsAdded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_added_total",
+		Help:      "Number of items put up for sale.",
+	})
+)
+
+// Outcome labels of DBDuration.
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
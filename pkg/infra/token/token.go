@@ -0,0 +1,97 @@
+// Package token issues and verifies user tokens. Tokens are JWTs signed
+// with HMAC-SHA256 that carry the user ID as subject.
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+)
+
+const issuer = "webshop"
+
+type claims struct {
+	Kind domain.TokenKind `json:"kind"`
+	jwt.RegisteredClaims
+}
+
+type Issuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	parser     *jwt.Parser
+}
+
+func New(cfg *config.AuthCfg) *Issuer {
+	return &Issuer{
+		secret:     []byte(cfg.Secret),
+		accessTTL:  cfg.AccessTTL,
+		refreshTTL: cfg.RefreshTTL,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithIssuer(issuer),
+			jwt.WithExpirationRequired(),
+		),
+	}
+}
+
+// Issue returns a new pair of access and refresh tokens of the user.
+func (i *Issuer) Issue(userID string) (*domain.TokenPair, error) {
+	now := time.Now()
+
+	access, err := i.sign(userID, domain.TokenAccess, now, i.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := i.sign(userID, domain.TokenRefresh, now, i.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(i.accessTTL / time.Second),
+	}, nil
+}
+
+// Verify checks the signature, expiry and kind of the token and returns
+// the ID of the user it was issued to.
+func (i *Issuer) Verify(token string, kind domain.TokenKind) (string, error) {
+	var c claims
+	if _, err := i.parser.ParseWithClaims(token, &c, i.key); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return "", domain.ErrTokenExpired
+		}
+
+		return "", domain.ErrInvalidToken
+	}
+
+	if c.Kind != kind || c.Subject == "" {
+		return "", domain.ErrInvalidToken
+	}
+
+	return c.Subject, nil
+}
+
+func (i *Issuer) sign(userID string, kind domain.TokenKind, now time.Time, ttl time.Duration) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{
+		Kind: kind,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}).SignedString(i.secret)
+}
+
+func (i *Issuer) key(*jwt.Token) (interface{}, error) {
+	return i.secret, nil
+}
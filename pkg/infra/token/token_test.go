@@ -0,0 +1,215 @@
+package token_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Pavel7004/WebShop/pkg/domain"
+	"github.com/Pavel7004/WebShop/pkg/infra/config"
+	"github.com/Pavel7004/WebShop/pkg/infra/token"
+)
+
+const (
+	secret = "test-secret"
+	userID = "user"
+)
+
+func newIssuer() *token.Issuer {
+	return token.New(&config.AuthCfg{
+		Secret:     secret,
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	})
+}
+
+// forge signs claims the way the issuer does unless the test changes
+// them, so a single claim or the method can be made wrong.
+func forge(t *testing.T, method jwt.SigningMethod, key interface{}, change func(jwt.MapClaims)) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"kind": string(domain.TokenAccess),
+		"iss":  "webshop",
+		"sub":  userID,
+		"iat":  now.Unix(),
+		"exp":  now.Add(time.Minute).Unix(),
+	}
+	if change != nil {
+		change(claims)
+	}
+
+	s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	return s
+}
+
+func TestIssueVerify(t *testing.T) {
+	i := newIssuer()
+
+	pair, err := i.Issue(userID)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if got, err := i.Verify(pair.AccessToken, domain.TokenAccess); err != nil || got != userID {
+		t.Errorf("Verify(access) = %q, %v, want %q", got, err, userID)
+	}
+
+	if got, err := i.Verify(pair.RefreshToken, domain.TokenRefresh); err != nil || got != userID {
+		t.Errorf("Verify(refresh) = %q, %v, want %q", got, err, userID)
+	}
+
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != 60 {
+		t.Errorf("pair = %s, expires in %d, want Bearer, expires in 60", pair.TokenType, pair.ExpiresIn)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	i := newIssuer()
+
+	pair, err := i.Issue(userID)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		kind  domain.TokenKind
+		want  error
+	}{
+		{
+			name:  "RefreshAsAccess",
+			token: pair.RefreshToken,
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "AccessAsRefresh",
+			token: pair.AccessToken,
+			kind:  domain.TokenRefresh,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name: "Expired",
+			token: forge(t, jwt.SigningMethodHS256, []byte(secret), func(c jwt.MapClaims) {
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+			}),
+			kind: domain.TokenAccess,
+			want: domain.ErrTokenExpired,
+		},
+		{
+			name: "NoExpiry",
+			token: forge(t, jwt.SigningMethodHS256, []byte(secret), func(c jwt.MapClaims) {
+				delete(c, "exp")
+			}),
+			kind: domain.TokenAccess,
+			want: domain.ErrInvalidToken,
+		},
+		{
+			name: "OtherIssuer",
+			token: forge(t, jwt.SigningMethodHS256, []byte(secret), func(c jwt.MapClaims) {
+				c["iss"] = "elsewhere"
+			}),
+			kind: domain.TokenAccess,
+			want: domain.ErrInvalidToken,
+		},
+		{
+			name: "NoSubject",
+			token: forge(t, jwt.SigningMethodHS256, []byte(secret), func(c jwt.MapClaims) {
+				delete(c, "sub")
+			}),
+			kind: domain.TokenAccess,
+			want: domain.ErrInvalidToken,
+		},
+		{
+			name:  "AlgNone",
+			token: forge(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, nil),
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "OtherHMAC",
+			token: forge(t, jwt.SigningMethodHS512, []byte(secret), nil),
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "OtherSecret",
+			token: forge(t, jwt.SigningMethodHS256, []byte("other-secret"), nil),
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "TamperedPayload",
+			token: tamperPayload(t, pair.AccessToken),
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "TamperedSignature",
+			token: tamperSignature(pair.AccessToken),
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "Malformed",
+			token: "not.a.token",
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+		{
+			name:  "Empty",
+			token: "",
+			kind:  domain.TokenAccess,
+			want:  domain.ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := i.Verify(tt.token, tt.kind)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+
+			if got != "" {
+				t.Errorf("Verify() user = %q, want none", got)
+			}
+		})
+	}
+}
+
+// tamperPayload puts another subject in the token keeping its signature.
+func tamperPayload(t *testing.T, s string) string {
+	t.Helper()
+
+	parts := strings.Split(s, ".")
+
+	other := forge(t, jwt.SigningMethodHS256, []byte(secret), func(c jwt.MapClaims) {
+		c["sub"] = "admin"
+	})
+
+	return parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+}
+
+// tamperSignature changes the first character of the signature. The last
+// one may carry padding bits only and decode to the same signature.
+func tamperSignature(s string) string {
+	i := strings.LastIndexByte(s, '.') + 1
+
+	c := byte('A')
+	if s[i] == 'A' {
+		c = 'B'
+	}
+
+	return s[:i] + string(c) + s[i+1:]
+}